@@ -25,25 +25,68 @@ import (
 )
 
 var generateCmd = &cobra.Command{
-	Use:              "generate",
-	Short:            "Generates specific number of chef nodes, actions and/or compliance reports",
+	Use:   "generate",
+	Short: "Generates specific number of chef nodes, actions and/or compliance reports",
+	Long: `Generates specific number of chef nodes, actions and/or compliance reports.
+Without a subcommand it runs every generator that can deliver data with the configuration,
+use the ccrs, actions, compliance or liveness subcommands to select a single generator.`,
 	TraverseChildren: true,
 	Run: func(cmd *cobra.Command, args []string) {
-		config, err := configFromViper()
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error": err,
-			}).Fatal("Could not load chef-load config file")
-		}
+		generate(nil)
+	},
+}
 
-		chef_load.GenerateData(config)
+// generatorCmds select a single generator of the generate command
+var generatorCmds = []*cobra.Command{
+	{
+		Use:   chef_load.CCRsGenerator,
+		Short: "Generates chef-client runs",
+	},
+	{
+		Use:   chef_load.ActionsGenerator,
+		Short: "Generates chef actions, requires data_collector_url",
+	},
+	{
+		Use:   chef_load.ComplianceGenerator,
+		Short: "Generates compliance reports",
+	},
+	{
+		Use:   chef_load.LivenessGenerator,
+		Short: "Generates liveness agent data, requires data_collector_url",
 	},
 }
 
+// generate loads and validates the config and runs the generators, or the
+// default ones when there are none
+func generate(generators []string) {
+	config, err := configFromViper()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("Could not load chef-load config file")
+	}
+	if generators == nil {
+		generators = chef_load.DefaultGenerators(config)
+	}
+	exitOnProblems(chef_load.ValidateGenerate(config, generators))
+
+	if err := chef_load.GenerateData(config, generators); err != nil {
+		log.WithField("error", err).Fatal("Could not generate data")
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
-	generateCmd.Flags().Int("days_back", 0, "The number days back for historical data")
-	generateCmd.Flags().Int("threads", 3000, "Number of simultaneous goroutines to spawn for historical data")
-	generateCmd.Flags().Int("sleep_time_on_failure", 5, "Time in seconds to sleep when a failure is detected for historical data")
-	viper.BindPFlags(generateCmd.Flags())
+	for _, generatorCmd := range generatorCmds {
+		generator := generatorCmd.Use
+		generatorCmd.Args = cobra.NoArgs
+		generatorCmd.Run = func(cmd *cobra.Command, args []string) {
+			generate([]string{generator})
+		}
+		generateCmd.AddCommand(generatorCmd)
+	}
+	generateCmd.PersistentFlags().Int("days_back", 0, "The number days back for historical data")
+	generateCmd.PersistentFlags().Int("threads", 3000, "Number of simultaneous goroutines to spawn for historical data")
+	generateCmd.PersistentFlags().Int("sleep_time_on_failure", 5, "Time in seconds to sleep when a failure is detected for historical data")
+	viper.BindPFlags(generateCmd.PersistentFlags())
 }
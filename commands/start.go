@@ -35,6 +35,8 @@ var startCmd = &cobra.Command{
 				"error": err,
 			}).Fatal("Could not load chef-load config file")
 		}
+		// Unified nodes send the compliance reports of the matrix too
+		validateConfig(config, config.UnifiedNodes)
 
 		chef_load.Start(config)
 	},
@@ -0,0 +1,68 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	chef_load "github.com/chef/chef-load/lib"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the chef-load configuration and the files it references",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := configFromViper()
+		if err != nil {
+			fmt.Println("Invalid chef-load configuration:", err)
+			os.Exit(1)
+		}
+
+		problems := chef_load.ValidateConfig(config, true)
+		if len(problems) != 0 {
+			fmt.Printf("Found %d problem(s) with the chef-load configuration:\n", len(problems))
+			for _, problem := range problems {
+				fmt.Println("  -", problem)
+			}
+			os.Exit(1)
+		}
+		fmt.Println("The chef-load configuration is valid")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+// validateConfig stops chef-load when the config has any problem
+func validateConfig(config *chef_load.Config, checkComplianceSamples bool) {
+	exitOnProblems(chef_load.ValidateConfig(config, checkComplianceSamples))
+}
+
+// exitOnProblems stops chef-load when there is any problem with the config
+func exitOnProblems(problems []error) {
+	for _, problem := range problems {
+		log.WithField("error", problem).Error("Invalid chef-load configuration")
+	}
+	if len(problems) != 0 {
+		log.Fatalf("Found %d problem(s) with the chef-load configuration, run 'chef-load validate' for details", len(problems))
+	}
+}
@@ -7,7 +7,6 @@ toolchain go1.24.5
 require (
 	github.com/go-chef/chef v0.30.1
 	github.com/google/uuid v1.6.0
-	github.com/icrowley/fake v0.0.0-20240710202011-f797eb4a99c0
 	github.com/sirupsen/logrus v1.9.3
 	github.com/spf13/cobra v1.10.1
 	github.com/spf13/viper v1.21.0
@@ -15,7 +14,6 @@ require (
 )
 
 require (
-	github.com/corpix/uarand v0.2.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.4.0 // indirect
@@ -0,0 +1,239 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+import (
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Groups that exist in every Chef Server organization
+var orgGroups = []string{"admins", "billing-admins", "clients", "users"}
+
+// actionSimulation holds the entities that chef-load is simulating so that
+// the generated actions reference them instead of random names
+type actionSimulation struct {
+	orgName          string
+	serverFQDN       string
+	environment      string
+	nodeNames        []string
+	runLists         []runList
+	cookbooks        []string
+	cookbookVersions map[string]string
+	roles            []string
+	adminUsers       []string
+}
+
+func newActionSimulation(config *Config) *actionSimulation {
+	s := &actionSimulation{
+		environment:      config.ChefEnvironment,
+		cookbookVersions: map[string]string{},
+		adminUsers:       config.ActionAdminUsers,
+	}
+
+	if chefServerURL, err := url.Parse(config.ChefServerURL); err == nil {
+		s.serverFQDN = chefServerURL.Host
+		if path := strings.Split(chefServerURL.Path, "/"); len(path) > 2 {
+			s.orgName = path[2]
+		}
+	}
+
+	for i := 1; i <= config.NumNodes; i++ {
+		s.nodeNames = append(s.nodeNames, config.NodeNamePrefix+"-"+strconv.Itoa(i))
+	}
+
+	s.runLists = parseRunLists(config.RunLists)
+	if len(s.runLists) == 0 {
+		s.runLists = []runList{parseRunList(config.RunList)}
+	}
+
+	// Collect the cookbooks and roles the nodes converge with
+	seenRoles := map[string]bool{}
+	for _, rl := range s.runLists {
+		for _, rli := range rl {
+			switch rli.itemType {
+			case "recipe":
+				cookbookName := strings.Split(rli.name, "::")[0]
+				if _, seen := s.cookbookVersions[cookbookName]; !seen {
+					s.cookbooks = append(s.cookbooks, cookbookName)
+				}
+				if rli.version != "" || s.cookbookVersions[cookbookName] == "" {
+					s.cookbookVersions[cookbookName] = rli.version
+				}
+			case "role":
+				if !seenRoles[rli.name] {
+					seenRoles[rli.name] = true
+					s.roles = append(s.roles, rli.name)
+				}
+			}
+		}
+	}
+
+	// Fallback to random data for the entities the config doesn't provide
+	if len(s.nodeNames) == 0 {
+		s.nodeNames = []string{config.NodeNamePrefix + "-1"}
+	}
+	if len(s.cookbooks) == 0 {
+		s.cookbooks = randCookbooks
+	}
+	if len(s.roles) == 0 {
+		s.roles = roles
+	}
+	if len(s.adminUsers) == 0 {
+		s.adminUsers = []string{"admin"}
+	}
+	return s
+}
+
+func (s *actionSimulation) randomNodeName() string {
+	return s.nodeNames[rand.Intn(len(s.nodeNames))]
+}
+
+func (s *actionSimulation) randomCookbook() string {
+	return s.cookbooks[rand.Intn(len(s.cookbooks))]
+}
+
+func (s *actionSimulation) randomRole() string {
+	return s.roles[rand.Intn(len(s.roles))]
+}
+
+func (s *actionSimulation) randomAdminUser() string {
+	return s.adminUsers[rand.Intn(len(s.adminUsers))]
+}
+
+func (s *actionSimulation) randomRunList() []string {
+	rl := s.runLists[rand.Intn(len(s.runLists))].toStringSlice()
+	if rl == nil {
+		return []string{}
+	}
+	return rl
+}
+
+// cookbookVersion returns the version pinned in the run lists, or a random one
+func (s *actionSimulation) cookbookVersion(cookbookName string) string {
+	if version := s.cookbookVersions[cookbookName]; version != "" {
+		return version
+	}
+	return randomCookbookVersion()
+}
+
+// populate sets the entity, requestor and organization of the action using
+// the simulated entities
+func (s *actionSimulation) populate(ar *actionRequest) {
+	ar.OrganizationName = s.orgName
+	ar.ServiceHostname = s.serverFQDN
+	ar.RequestorName = s.randomAdminUser()
+	ar.RequestorType = "user"
+
+	switch ar.actionType {
+	case nodeAction:
+		ar.EntityName = s.randomNodeName()
+		// Nodes update themselves at the end of every chef-client run
+		if ar.Task == tasksString[updateTask] {
+			ar.RequestorName = ar.EntityName
+			ar.RequestorType = "client"
+		}
+	case clientAction:
+		ar.EntityName = s.randomNodeName()
+	case cookbookAction:
+		ar.EntityName = s.randomCookbook()
+	case versionAction:
+		// Cookbook versions are uploaded, not updated in place
+		ar.SetTask(createTask)
+		ar.ParentType = actionTypeString[cookbookAction]
+		ar.ParentName = s.randomCookbook()
+		ar.EntityName = s.cookbookVersion(ar.ParentName)
+	case environmentAction:
+		ar.EntityName = s.environment
+	case roleAction:
+		ar.EntityName = s.randomRole()
+	case policyAction:
+		ar.ParentType = "policy_group"
+		ar.ParentName = s.environment
+		ar.EntityName = s.randomCookbook()
+	case groupAction:
+		ar.EntityName = orgGroups[rand.Intn(len(orgGroups))]
+	case organizationAction:
+		// When there is an organization action the organization_name must be empty
+		ar.OrganizationName = ""
+		ar.EntityName = s.orgName
+	case permissionAction:
+		ar.ParentType = actionTypeString[groupAction]
+		ar.ParentName = orgGroups[rand.Intn(len(orgGroups))]
+		ar.EntityName = s.randomAdminUser()
+	case userAction:
+		ar.EntityName = s.randomAdminUser()
+	case dataBagAction:
+		ar.EntityName = randomEntityName()
+	case itemAction:
+		ar.ParentType = actionTypeString[dataBagAction]
+		ar.ParentName = randomEntityName()
+		ar.EntityName = s.randomNodeName()
+	default:
+	}
+}
+
+// nodeReplacementActions returns the actions the Chef Server records when a
+// node is decommissioned and a new one bootstraps in its place
+func (s *actionSimulation) nodeReplacementActions(oldNodeName, newNodeName string) []*actionRequest {
+	var actions []*actionRequest
+	for _, replacement := range []struct {
+		aType    ActionType
+		task     Task
+		nodeName string
+	}{
+		{nodeAction, deleteTask, oldNodeName},
+		{clientAction, deleteTask, oldNodeName},
+		{clientAction, createTask, newNodeName},
+		{nodeAction, createTask, newNodeName},
+	} {
+		ar := newActionRequest(replacement.aType)
+		ar.SetTask(replacement.task)
+		ar.EntityName = replacement.nodeName
+		ar.OrganizationName = s.orgName
+		ar.ServiceHostname = s.serverFQDN
+		ar.RequestorName = s.randomAdminUser()
+		ar.RequestorType = "user"
+		// The new node creates itself with its own client
+		if replacement.aType == nodeAction && replacement.task == createTask {
+			ar.RequestorName = newNodeName
+			ar.RequestorType = "client"
+		}
+		ar.setData(s.environment, s.randomRunList())
+		actions = append(actions, ar)
+	}
+	return actions
+}
+
+// cookbookReleaseAction returns the action the Chef Server records when the
+// version after the current one of the cookbook is uploaded
+func (s *actionSimulation) cookbookReleaseAction(cookbookName, currentVersion string) *actionRequest {
+	ar := newActionRequest(versionAction)
+	ar.SetTask(createTask)
+	ar.ParentType = actionTypeString[cookbookAction]
+	ar.ParentName = cookbookName
+	ar.EntityName = bumpVersion(currentVersion, 1)
+	ar.OrganizationName = s.orgName
+	ar.ServiceHostname = s.serverFQDN
+	ar.RequestorName = s.randomAdminUser()
+	ar.RequestorType = "user"
+	ar.setData(s.environment, s.randomRunList())
+	return ar
+}
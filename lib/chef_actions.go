@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -46,8 +47,7 @@ const (
 	itemAction
 	versionAction
 	clientAction
-	// TODO: (@afiune) Add latter when compliance joins the pool party
-	//profileAction
+	profileAction
 )
 
 // Strings of the supported Action Type list above
@@ -65,8 +65,7 @@ var actionTypeString = map[ActionType]string{
 	itemAction:         "item",
 	versionAction:      "version",
 	clientAction:       "client",
-	// TODO: (@afiune) Add latter when compliance joins the pool party
-	//profileAction:     "profile",
+	profileAction:      "profile",
 }
 
 // Task will be our enum to identity a list of tasks
@@ -139,18 +138,149 @@ func newActionRequest(aType ActionType) *actionRequest {
 	return a
 }
 
-func newRandomActionRequest(aType ActionType) *actionRequest {
+// actionGenerator creates the actions that chef-load sends, they are either
+// random or correlated with the simulated nodes when a simulation is provided
+type actionGenerator struct {
+	mix        *actionMix
+	times      *actionTimeDistribution
+	simulation *actionSimulation
+	profiles   []complianceProfile
+}
+
+// newActionGenerator returns a generator whose actions are recorded within the
+// daysBack days before end, a zero end follows the current time
+func newActionGenerator(config *Config, daysBack int, end time.Time) *actionGenerator {
+	g := &actionGenerator{
+		mix:      newActionMix(config.Actions),
+		times:    newActionTimeDistribution(config.ActionTimeDistribution, daysBack, end),
+		profiles: matrixProfiles(config.Matrix),
+	}
+	if len(g.profiles) == 0 {
+		g.profiles = []complianceProfile{{name: "linux-baseline", version: "2.2.0"}}
+	}
+	if config.SimulatedActions {
+		g.simulation = newActionSimulation(config)
+	}
+	return g
+}
+
+func (g *actionGenerator) randomActionType() ActionType {
+	return g.mix.randomActionType()
+}
+
+func (g *actionGenerator) newAction(aType ActionType) *actionRequest {
 	a := newActionRequest(aType)
-	a.randomize()
+	a.SetTask(g.mix.randomTask(aType))
+	if g.simulation != nil {
+		g.simulation.populate(a)
+		a.setData(g.simulation.environment, g.simulation.randomRunList())
+	} else {
+		a.randomize()
+		runList, _ := genRandomRunList()
+		a.setData(getRandom("environment"), runList)
+	}
+	if aType == profileAction {
+		g.setProfile(a)
+	}
+	a.RecordedAt = g.times.randomTime()
 	return a
 }
 
-func randomActionType() ActionType {
-	return ActionType(rand.Intn(len(actionTypeString)))
+// setProfile turns the action into the upload (create), update or delete of
+// one of the compliance profiles of the matrix samples. The Automate compliance
+// service records profiles under the user that owns them.
+func (g *actionGenerator) setProfile(ar *actionRequest) {
+	profile := g.profiles[rand.Intn(len(g.profiles))]
+	ar.EntityName = profile.name
+	ar.ParentType = actionTypeString[userAction]
+	ar.ParentName = ar.RequestorName
+	ar.RequestorType = "user"
+	ar.Data = map[string]interface{}{}
+	if ar.Task != tasksString[deleteTask] {
+		ar.Data = map[string]interface{}{
+			"name":    profile.name,
+			"version": profile.version,
+			"owner":   ar.RequestorName,
+			"title":   profile.name + " profile",
+		}
+	}
+}
+
+// actionMix picks the action types and their tasks according to the weights
+// of the [actions] config section
+type actionMix struct {
+	typeWeights []float64
+	taskWeights map[ActionType][]float64
+}
+
+func newActionMix(actions *Actions) *actionMix {
+	m := &actionMix{
+		typeWeights: make([]float64, len(actionTypeString)),
+		taskWeights: map[ActionType][]float64{},
+	}
+
+	for aType, name := range actionTypeString {
+		// Compliance profile actions are only sent on demand
+		if aType == profileAction && !actions.IncludeProfiles {
+			continue
+		}
+
+		m.typeWeights[aType] = 1.0
+		if weight, ok := actions.Weights[name]; ok {
+			m.typeWeights[aType] = weight
+		}
+
+		m.taskWeights[aType] = make([]float64, len(tasksString))
+		for task, taskName := range tasksString {
+			m.taskWeights[aType][task] = 1.0
+			if weight, ok := actions.TaskWeights[name][taskName]; ok {
+				m.taskWeights[aType][task] = weight
+			}
+		}
+	}
+	return m
 }
 
-func randomTask() Task {
-	return Task(rand.Intn(len(tasksString)))
+func (m *actionMix) randomActionType() ActionType {
+	return ActionType(weightedChoice(m.typeWeights))
+}
+
+func (m *actionMix) randomTask(aType ActionType) Task {
+	return Task(weightedChoice(m.taskWeights[aType]))
+}
+
+// weightedChoice returns a random index of the provided weights, the bigger
+// the weight the more likely its index is returned
+func weightedChoice(weights []float64) int {
+	return weightedIndex(weights, rand.Float64())
+}
+
+// weightedIndex returns the index of the weights that the fraction (0.0 - 1.0)
+// falls in once the weights are laid out one after the other
+func weightedIndex(weights []float64, fraction float64) int {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+
+	r := fraction * total
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		if r < weight {
+			return i
+		}
+		r -= weight
+	}
+
+	// Floating point rounding, return the last index that has a weight
+	for i := len(weights) - 1; i >= 0; i-- {
+		if weights[i] > 0 {
+			return i
+		}
+	}
+	return 0
 }
 
 func (ar *actionRequest) SetTask(t Task) {
@@ -173,26 +303,109 @@ func randomRequestorName() string {
 func randomCookbookVersion() string {
 	return strconv.Itoa(rand.Intn(9)) + "." +
 		strconv.Itoa(rand.Intn(9)) + "." +
-		strconv.Itoa(rand.Intn(9)) + "."
+		strconv.Itoa(rand.Intn(9))
+}
+
+// Supported distributions of the recorded_at time of the generated actions
+const (
+	uniformActionTimes       = "uniform"
+	businessHoursActionTimes = "business_hours"
+	burstyActionTimes        = "bursty"
+)
+
+// actionTimeDistribution spreads the recorded_at time of the actions over a
+// historical window that ends at end, or at the current time when end is zero
+type actionTimeDistribution struct {
+	kind   string
+	end    time.Time
+	window time.Duration
+	// bursts are how long before the end of the window each burst happens
+	bursts []time.Duration
+}
+
+// newActionTimeDistribution returns a distribution over the daysBack days
+// before end, when daysBack is not set we default to the last week. A zero end
+// keeps the window on the current time, for the actions that chef-load sends
+// as it runs
+func newActionTimeDistribution(kind string, daysBack int, end time.Time) *actionTimeDistribution {
+	if daysBack <= 0 {
+		daysBack = 7
+	}
+	d := &actionTimeDistribution{
+		kind:   kind,
+		end:    end,
+		window: time.Duration(daysBack) * 24 * time.Hour,
+	}
+
+	// A bursty distribution concentrates most of the actions around a couple
+	// of random points in time per day, like a team deploying a release
+	if kind == burstyActionTimes {
+		for i := 0; i < daysBack*2; i++ {
+			d.bursts = append(d.bursts, d.randomAge())
+		}
+	}
+	return d
 }
 
-// Get a random hour for the last week.
-func randomTime() time.Time {
-	numberOfMinutesBeforeNow := rand.Intn(7 * 24 * 60)
+// endTime returns the end of the window
+func (d *actionTimeDistribution) endTime() time.Time {
+	if d.end.IsZero() {
+		return time.Now()
+	}
+	return d.end
+}
 
-	numberOfNanosecondBeforeNow := time.Duration(time.Minute * time.Duration(numberOfMinutesBeforeNow))
+// randomAge returns a random duration within the window
+func (d *actionTimeDistribution) randomAge() time.Duration {
+	return time.Duration(rand.Int63n(int64(d.window)))
+}
 
-	return time.Now().Add(-numberOfNanosecondBeforeNow)
+func (d *actionTimeDistribution) uniformTime() time.Time {
+	return d.endTime().Add(-d.randomAge())
+}
+
+// isBusinessHours returns true for weekdays between 9am and 5pm
+func isBusinessHours(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return t.Hour() >= 9 && t.Hour() < 17
+}
+
+// Get a random time within the window of the distribution
+func (d *actionTimeDistribution) randomTime() time.Time {
+	switch d.kind {
+	case businessHoursActionTimes:
+		// Only one in ten actions happen outside of business hours
+		for {
+			t := d.uniformTime()
+			if isBusinessHours(t) || rand.Float64() < 0.1 {
+				return t
+			}
+		}
+	case burstyActionTimes:
+		// Most actions happen within about 15 minutes of a burst
+		if rand.Float64() < 0.8 {
+			end := d.endTime()
+			burst := end.Add(-d.bursts[rand.Intn(len(d.bursts))])
+			t := burst.Add(time.Duration(rand.NormFloat64() * float64(15*time.Minute)))
+			if t.After(end) {
+				return end
+			}
+			return t
+		}
+		return d.uniformTime()
+	default:
+		return d.uniformTime()
+	}
 }
 
 // This function will randomize the Chef Action instance depending on the action type
 func (ar *actionRequest) randomize() {
-	ar.SetTask(randomTask())
 	ar.EntityName = randomEntityName()
 	ar.RequestorName = randomRequestorName()
 	ar.ServiceHostname = getRandom("source_fqdn")
 	ar.OrganizationName = getRandom("organization")
-	ar.RecordedAt = randomTime()
 
 	// Custom settings for specific actions
 	//
@@ -228,20 +441,145 @@ func (ar *actionRequest) randomize() {
 		ar.ParentType = actionTypeString[dataBagAction]
 		ar.ParentName = randomEntityName()
 	case clientAction:
-	// TODO: (@afiune) Add latter when compliance joins the pool party
-	//case profileAction:
+	case profileAction:
 	default:
 	}
 }
 
+// setData fills the data field with the payload that the Chef Server would
+// send for the entity type of the action, the environment and run list are
+// used by the entities that have them (nodes and roles)
+func (ar *actionRequest) setData(environment string, runList []string) {
+	if ar.Task == tasksString[deleteTask] {
+		ar.Data = map[string]interface{}{}
+		return
+	}
+
+	switch ar.actionType {
+	case nodeAction:
+		ar.Data = map[string]interface{}{
+			"name":             ar.EntityName,
+			"chef_type":        "node",
+			"json_class":       "Chef::Node",
+			"chef_environment": environment,
+			"run_list":         runList,
+			"normal":           map[string]interface{}{"tags": []string{}},
+		}
+	case cookbookAction:
+		ar.Data = map[string]interface{}{
+			"cookbook_name": ar.EntityName,
+			"name":          ar.EntityName,
+			"json_class":    "Chef::CookbookVersion",
+			"chef_type":     "cookbook_version",
+		}
+	case versionAction:
+		ar.Data = map[string]interface{}{
+			"cookbook_name": ar.ParentName,
+			"name":          ar.ParentName + "-" + ar.EntityName,
+			"version":       ar.EntityName,
+			"json_class":    "Chef::CookbookVersion",
+			"chef_type":     "cookbook_version",
+			"metadata": map[string]interface{}{
+				"name":         ar.ParentName,
+				"version":      ar.EntityName,
+				"dependencies": map[string]string{},
+			},
+		}
+	case dataBagAction:
+		ar.Data = map[string]interface{}{
+			"name":       ar.EntityName,
+			"chef_type":  "data_bag",
+			"json_class": "Chef::DataBag",
+		}
+	case itemAction:
+		ar.Data = map[string]interface{}{
+			"id":        ar.EntityName,
+			"data_bag":  ar.ParentName,
+			"chef_type": "data_bag_item",
+		}
+	case environmentAction:
+		ar.Data = map[string]interface{}{
+			"name":                ar.EntityName,
+			"description":         "",
+			"chef_type":           "environment",
+			"json_class":          "Chef::Environment",
+			"cookbook_versions":   map[string]string{},
+			"default_attributes":  map[string]interface{}{},
+			"override_attributes": map[string]interface{}{},
+		}
+	case roleAction:
+		ar.Data = map[string]interface{}{
+			"name":                ar.EntityName,
+			"description":         "",
+			"chef_type":           "role",
+			"json_class":          "Chef::Role",
+			"run_list":            runList,
+			"env_run_lists":       map[string]interface{}{},
+			"default_attributes":  map[string]interface{}{},
+			"override_attributes": map[string]interface{}{},
+		}
+	case policyAction:
+		ar.Data = map[string]interface{}{
+			"name":        ar.EntityName,
+			"revision_id": strings.Replace(uuid.New().String(), "-", "", -1),
+			"run_list":    runList,
+		}
+	case groupAction:
+		ar.Data = map[string]interface{}{
+			"groupname": ar.EntityName,
+			"orgname":   ar.OrganizationName,
+			"actors":    []string{ar.RequestorName},
+			"groups":    []string{},
+		}
+	case organizationAction:
+		ar.Data = map[string]interface{}{
+			"name":      ar.EntityName,
+			"full_name": ar.EntityName,
+		}
+	case permissionAction:
+		ar.Data = map[string]interface{}{
+			"grant": map[string]interface{}{
+				"actors": []string{ar.EntityName},
+				"groups": []string{ar.ParentName},
+			},
+		}
+	case userAction:
+		ar.Data = map[string]interface{}{
+			"username":     ar.EntityName,
+			"display_name": ar.EntityName,
+			"email":        ar.EntityName + "@example.com",
+		}
+	case clientAction:
+		ar.Data = map[string]interface{}{
+			"name":       ar.EntityName,
+			"clientname": ar.EntityName,
+			"validator":  false,
+			"admin":      false,
+		}
+	default:
+		ar.Data = map[string]interface{}{}
+	}
+}
+
 func (ar *actionRequest) String() string {
 	return fmt.Sprintf("%s::%s", ar.EntityType, ar.Task)
 }
 
 func GenerateChefActions(config *Config, requests chan *request) error {
+	var (
+		channels         []<-chan int
+		actionsIngested  int64 = 0
+		actionsRejected  int64 = 0
+		actionsProcessed int64 = 0
+		timeMarker             = time.Now()
+	)
+
 	log.WithFields(log.Fields{
-		"actions":     config.NumActions,
-		"random_data": config.RandomData,
+		"actions":           config.NumActions,
+		"days_back":         config.DaysBack,
+		"time_distribution": config.ActionTimeDistribution,
+		"random_data":       config.RandomData,
+		"goroutines":        config.Threads,
 	}).Info("Generating chef actions")
 
 	rand.Seed(time.Now().UTC().UnixNano())
@@ -255,14 +593,63 @@ func GenerateChefActions(config *Config, requests chan *request) error {
 		return errors.New(fmt.Sprintf("Error creating DataCollectorClient: %+v \n", err))
 	}
 
-	for i := 1; i <= config.NumActions; i++ {
-		// TODO: Check the errors
-		chefAction(config, randomActionType(), dataCollectorClient)
+	generator := newActionGenerator(config, config.DaysBack, time.Now())
+
+	// Send the actions in batches of config.Threads goroutines
+	for i := 0; i < config.NumActions; i += config.Threads {
+		channels = make([]<-chan int, min(config.Threads, config.NumActions-i))
+		for j := range channels {
+			channels[j] = asyncChefAction(generator, generator.randomActionType(), dataCollectorClient)
+		}
+
+		rejects := false
+		for code := range merge(channels...) {
+			actionsProcessed++
+			if code != 200 {
+				rejects = true
+				actionsRejected++
+			} else {
+				actionsIngested++
+			}
+		}
+
+		// When we start rejecting/dropping messages we will wait
+		// an interval of time to let the system digest
+		if rejects {
+			log.WithFields(log.Fields{
+				"total_actions":                      config.NumActions,
+				"total_actions_processed":            actionsProcessed,
+				"sleep":                              fmt.Sprintf("%ds", config.SleepTimeOnFailure),
+				"time_elapsed_since_last_failure":    time.Now().Sub(timeMarker),
+				"action_ingested_since_last_failure": actionsIngested,
+				"action_rejected_since_last_failure": actionsRejected,
+				"goroutines":                         config.Threads,
+				"days_back":                          config.DaysBack,
+			}).Info("Sleeping")
+			time.Sleep(time.Second * time.Duration(config.SleepTimeOnFailure))
+
+			actionsIngested = 0
+			actionsRejected = 0
+			timeMarker = time.Now()
+		}
 	}
 	return nil
 }
 
-func chefAction(config *Config, aType ActionType, dataCollectorClient *DataCollectorClient) (int, error) {
-	action := newRandomActionRequest(aType)
+func asyncChefAction(generator *actionGenerator, aType ActionType, dataCollectorClient *DataCollectorClient) <-chan int {
+	out := make(chan int)
+	go func() {
+		code, _ := chefAction(generator, aType, dataCollectorClient)
+		out <- code
+		close(out)
+	}()
+	return out
+}
+
+func chefAction(generator *actionGenerator, aType ActionType, dataCollectorClient *DataCollectorClient) (int, error) {
+	return sendChefAction(generator.newAction(aType), dataCollectorClient)
+}
+
+func sendChefAction(action *actionRequest, dataCollectorClient *DataCollectorClient) (int, error) {
 	return chefAutomateSendMessage(dataCollectorClient, action.String(), action)
 }
@@ -0,0 +1,85 @@
+package chef_load
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionTimeDistributionWindow(t *testing.T) {
+	for _, kind := range []string{uniformActionTimes, businessHoursActionTimes, burstyActionTimes} {
+		times := newActionTimeDistribution(kind, 3, time.Now())
+		start := times.end.Add(-times.window).Add(-time.Hour)
+
+		for i := 0; i < 1000; i++ {
+			recordedAt := times.randomTime()
+			assert.False(t, recordedAt.After(times.end), "%s: %s is in the future", kind, recordedAt)
+			assert.True(t, recordedAt.After(start), "%s: %s is before days_back", kind, recordedAt)
+		}
+	}
+}
+
+func TestActionTimeDistributionDefaultsToLastWeek(t *testing.T) {
+	assert.Equal(t, 7*24*time.Hour, newActionTimeDistribution(uniformActionTimes, 0, time.Now()).window)
+}
+
+func TestActionTimeDistributionFollowsCurrentTime(t *testing.T) {
+	times := newActionTimeDistribution(burstyActionTimes, 1, time.Time{})
+	end := times.endTime()
+	time.Sleep(time.Millisecond)
+	assert.True(t, times.endTime().After(end))
+	for i := 0; i < 100; i++ {
+		assert.False(t, times.randomTime().After(time.Now()))
+	}
+}
+
+func TestActionSimulationEntities(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/organizations/demo/"
+	config.NumNodes = 2
+	config.RunList = []string{"role[web]", "recipe[nginx::default@1.2.3]", "nginx", "recipe[base]"}
+
+	s := newActionSimulation(&config)
+	assert.Equal(t, "demo", s.orgName)
+	assert.Equal(t, "chef.example.com", s.serverFQDN)
+	assert.Equal(t, []string{"chef-load-1", "chef-load-2"}, s.nodeNames)
+	assert.Equal(t, []string{"nginx", "base"}, s.cookbooks)
+	assert.Equal(t, []string{"web"}, s.roles)
+	assert.Equal(t, "1.2.3", s.cookbookVersion("nginx"))
+
+	actions := s.nodeReplacementActions("chef-load-1", "chef-load-3")
+	assert.Len(t, actions, 4)
+	assert.Equal(t, "node::delete", actions[0].String())
+	assert.Equal(t, "chef-load-3", actions[3].RequestorName)
+}
+
+func TestActionMix(t *testing.T) {
+	actions := &Actions{
+		Weights:     map[string]float64{"cookbook": 0, "node": 0, "client": 0, "version": 0, "bag": 0, "environment": 0, "role": 0, "policy": 0, "group": 0, "organization": 0, "permission": 0, "user": 0, "item": 0},
+		TaskWeights: map[string]map[string]float64{"item": {"create": 0, "delete": 0}},
+	}
+	assert.Error(t, actions.Validate(), "every action type has a zero weight")
+
+	actions.Weights["item"] = 1
+	assert.NoError(t, actions.Validate())
+
+	mix := newActionMix(actions)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, itemAction, mix.randomActionType())
+		assert.Equal(t, updateTask, mix.randomTask(itemAction))
+	}
+
+	actions.IncludeProfiles = true
+	assert.Equal(t, 1.0, newActionMix(actions).typeWeights[profileAction])
+
+	actions.Weights["nope"] = 1
+	assert.Error(t, actions.Validate())
+}
+
+func TestPolicyActionRunList(t *testing.T) {
+	a := newActionRequest(policyAction)
+	a.SetTask(updateTask)
+	a.setData("_default", []string{"recipe[base]", "role[web]"})
+	assert.Equal(t, []string{"recipe[base]", "role[web]"}, a.Data.(map[string]interface{})["run_list"])
+}
@@ -0,0 +1,156 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/go-chef/chef"
+	"github.com/google/uuid"
+)
+
+// ChefVersionWeight is the share of the nodes that run a chef-client version
+type ChefVersionWeight struct {
+	Version string  `mapstructure:"version"`
+	Weight  float64 `mapstructure:"weight"`
+}
+
+// chefClientProfile holds how a chef-client version talks to the Chef Server
+// and the Data Collector
+type chefClientProfile struct {
+	// apiVersion is the X-Ops-Server-Api-Version of every API request
+	apiVersion string
+	// nodeSaveAllowlist saves only these automatic attributes, nil saves all of them
+	nodeSaveAllowlist []string
+	// messageVersion is the message_version of the Data Collector messages
+	messageVersion string
+	// policyfiles converges the policy of policy_name and policy_group
+	policyfiles bool
+}
+
+// defaultChefClientProfile applies to the versions without a built-in profile
+var defaultChefClientProfile = chefClientProfile{
+	apiVersion:     "1",
+	messageVersion: "1.1.0",
+	policyfiles:    true,
+}
+
+// chefClientProfiles are the built-in profiles by major version
+var chefClientProfiles = map[int]chefClientProfile{
+	12: {
+		apiVersion:     "0",
+		messageVersion: "1.0.0",
+		policyfiles:    false,
+	},
+	14: {
+		apiVersion:     "1",
+		messageVersion: "1.1.0",
+		policyfiles:    true,
+	},
+	16: {
+		apiVersion:        "1",
+		nodeSaveAllowlist: automaticAttributeAllowlist,
+		messageVersion:    "1.1.0",
+		policyfiles:       true,
+	},
+	18: {
+		apiVersion:        "1",
+		nodeSaveAllowlist: automaticAttributeAllowlist,
+		messageVersion:    "1.1.0",
+		policyfiles:       true,
+	},
+}
+
+// automaticAttributeAllowlist is a typical automatic_attribute_allowlist of the
+// fleets that limit the size of their node objects
+var automaticAttributeAllowlist = []string{
+	"fqdn", "hostname", "domain", "ipaddress", "macaddress",
+	"platform", "platform_family", "platform_version",
+	"os", "os_version", "kernel", "uptime_seconds", "ohai_time",
+	"roles", "recipes", "cookbooks", "chef_packages",
+}
+
+// chefClientProfileFor returns the profile of the chef-client version
+func chefClientProfileFor(chefVersion string) chefClientProfile {
+	major, err := strconv.Atoi(strings.SplitN(chefVersion, ".", 2)[0])
+	if err != nil {
+		return defaultChefClientProfile
+	}
+	if profile, ok := chefClientProfiles[major]; ok {
+		return profile
+	}
+	return defaultChefClientProfile
+}
+
+// nodeChefVersion returns the chef-client version of the node, a node keeps the
+// same version on every run
+func nodeChefVersion(config *Config, nodeName string) string {
+	if len(config.ChefVersions) == 0 {
+		return config.ChefVersion
+	}
+
+	weights := make([]float64, len(config.ChefVersions))
+	for i, chefVersion := range config.ChefVersions {
+		weights[i] = chefVersion.Weight
+	}
+	nodeUUID := uuid.NewMD5(uuid.NameSpaceDNS, []byte(nodeName))
+	fraction := float64(binary.BigEndian.Uint32(nodeUUID[4:8])) / float64(1<<32)
+	return config.ChefVersions[weightedIndex(weights, fraction)].Version
+}
+
+// forNode returns a copy of the config with the chef_version of the node
+func (c *Config) forNode(nodeName string) *Config {
+	if len(c.ChefVersions) == 0 {
+		return c
+	}
+	nodeConfig := *c
+	nodeConfig.ChefVersion = nodeChefVersion(c, nodeName)
+	return &nodeConfig
+}
+
+// clientCreateBody returns the body that creates the client of the node
+func clientCreateBody(config *Config, nodeName string) map[string]interface{} {
+	clientBody := map[string]interface{}{
+		"admin":     false,
+		"name":      nodeName,
+		"validator": false,
+	}
+	// create_key was added in API version 1
+	if config.ChefServerCreatesClientKey && chefClientProfileFor(config.ChefVersion).apiVersion != "0" {
+		clientBody["create_key"] = true
+	}
+	return clientBody
+}
+
+// nodeSaveBody returns the node that the chef-client version saves at the end of the run
+func nodeSaveBody(chefVersion string, node chef.Node) chef.Node {
+	allowlist := chefClientProfileFor(chefVersion).nodeSaveAllowlist
+	if allowlist == nil {
+		return node
+	}
+	automaticAttributes := map[string]interface{}{}
+	for _, name := range allowlist {
+		if value, ok := node.AutomaticAttributes[name]; ok {
+			automaticAttributes[name] = value
+		}
+	}
+	node.AutomaticAttributes = automaticAttributes
+	return node
+}
@@ -0,0 +1,65 @@
+package chef_load
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-chef/chef"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChefClientProfileFor(t *testing.T) {
+	assert.Equal(t, "0", chefClientProfileFor("12.22.5").apiVersion)
+	assert.Equal(t, "1.0.0", chefClientProfileFor("12.22.5").messageVersion)
+	assert.False(t, chefClientProfileFor("12.22.5").policyfiles)
+	assert.Equal(t, "1", chefClientProfileFor("18.2.7").apiVersion)
+	assert.NotNil(t, chefClientProfileFor("16.17.51").nodeSaveAllowlist)
+
+	// Versions without a built-in profile behave like chef-load always did
+	assert.Equal(t, defaultChefClientProfile, chefClientProfileFor("13.2.20"))
+	assert.Equal(t, defaultChefClientProfile, chefClientProfileFor("latest"))
+}
+
+func TestNodeChefVersion(t *testing.T) {
+	config := Default()
+	assert.Equal(t, "13.2.20", nodeChefVersion(&config, "chef-load-1"))
+
+	config.ChefVersions = []ChefVersionWeight{
+		{Version: "12.22.5", Weight: 1},
+		{Version: "18.2.7", Weight: 3},
+	}
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		nodeName := fmt.Sprintf("chef-load-%d", i)
+		version := nodeChefVersion(&config, nodeName)
+		// A node keeps its version on every run
+		assert.Equal(t, version, nodeChefVersion(&config, nodeName))
+		counts[version]++
+	}
+	assert.InDelta(t, 250, counts["12.22.5"], 60)
+	assert.InDelta(t, 750, counts["18.2.7"], 60)
+}
+
+func TestClientCreateBody(t *testing.T) {
+	config := Default()
+	assert.NotContains(t, clientCreateBody(&config, "node"), "create_key")
+
+	config.ChefServerCreatesClientKey = true
+	assert.Equal(t, true, clientCreateBody(&config, "node")["create_key"])
+
+	// API version 0 has no create_key
+	config.ChefVersion = "12.22.5"
+	assert.NotContains(t, clientCreateBody(&config, "node"), "create_key")
+}
+
+func TestNodeSaveBody(t *testing.T) {
+	node := chef.Node{Name: "node", AutomaticAttributes: map[string]interface{}{
+		"fqdn":     "node.example.com",
+		"packages": map[string]interface{}{"openssl": "1.1.1"},
+	}}
+
+	assert.Len(t, nodeSaveBody("14.15.6", node).AutomaticAttributes, 2)
+	assert.Equal(t, map[string]interface{}{"fqdn": "node.example.com"}, nodeSaveBody("18.2.7", node).AutomaticAttributes)
+	// The node of the run keeps every attribute
+	assert.Len(t, node.AutomaticAttributes, 2)
+}
@@ -25,9 +25,19 @@ import (
 
 	"github.com/go-chef/chef"
 	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// The API requests that start a chef-client run, see run_start_requests
+const (
+	nodeRunStartRequest        = "node"
+	rolesRunStartRequest       = "roles"
+	environmentRunStartRequest = "environment"
 )
 
 func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan *request, done chan int, nodeNumber uint32) {
+	// Every request of the run follows the chef-client version of the node
+	config = config.forNode(nodeName)
 	var (
 		nodeClient             chef.Client
 		ohaiJSON               = map[string]interface{}{}
@@ -37,7 +47,6 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 		runList                = parseRunList(config.RunList)
 		runLists               = parseRunLists(config.RunLists)
 		apiGetRequests         = config.APIGetRequests
-		sleepDuration          = config.SleepDuration
 		runUUID, _             = uuid.NewRandom()
 		reportUUID, _          = uuid.NewRandom()
 		skipClientCreation     = config.SkipClientCreation
@@ -54,6 +63,9 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 		dataCollectorAvailable = true
 		expandedRunList        []string
 		node                   chef.Node
+		nodePolicy             policy
+		nodeRoles              []string
+		usePolicy              = config.PolicyName != "" && chefClientProfileFor(config.ChefVersion).policyfiles
 		nodeDetails            = NodeDetails{
 			name:        nodeName,
 			ipAddr:      int2ip(nodeNumber).String(),
@@ -69,6 +81,14 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 			chefTags:    []string{"tag1", "tag2", "tag3"},
 		}
 	)
+	// Unified nodes converge and report compliance with the same details on every run
+	var unifiedNode unifiedNode
+	if config.UnifiedNodes {
+		unifiedNode = newUnifiedNode(config, nodeName)
+		nodeDetails = unifiedNode.NodeDetails
+		runList = unifiedNode.runList
+		runLists = nil
+	}
 	// Notify orchestrator when done.  THere's probably a cleaner way to
 	// do this.
 	closer := func() {
@@ -93,6 +113,12 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 	}
 
 	ohaiJSON["fqdn"] = nodeName
+	if config.UnifiedNodes {
+		unifiedNode.setAutomaticAttributes(ohaiJSON)
+		if len(complianceJSON) == 0 && hasComplianceSamples(config) {
+			complianceJSON = scaleReport(config.Matrix.Scaling, sampleReport(config, nodeDetails.platform))
+		}
+	}
 
 	if ohaiJSON["platform"] == nil {
 		ohaiJSON["platform"] = "rhel"
@@ -102,48 +128,56 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 		ohaiJSON["ipaddress"] = "169.254.169.254"
 	}
 
+	node = chef.Node{Name: nodeName}
 	if config.RunChefClient {
 
 		if firstRun && !skipClientCreation {
-			clientBody := map[string]interface{}{
-				"admin":     false,
-				"name":      nodeName,
-				"validator": false,
-			}
-			if config.ChefServerCreatesClientKey {
-				clientBody["create_key"] = config.ChefServerCreatesClientKey
-			}
-			apiRequest(nodeClient, nodeName, config.ChefVersion, "POST", "clients", clientBody, nil, nil, requests)
+			apiRequest(nodeClient, nodeName, config.ChefVersion, "POST", "clients", clientCreateBody(config, nodeName), nil, nil, requests)
 		}
 
-		res, err := apiRequest(nodeClient, nodeName, config.ChefVersion, "GET", "nodes/"+nodeName, nil, &node, nil, requests)
-		if err != nil {
-			if res != nil && res.StatusCode != 404 {
-				node = chef.Node{Name: nodeName}
-			}
-		}
-		if res != nil && res.StatusCode == 404 {
-			node = chef.Node{Name: nodeName, Environment: chefEnvironment}
-			_, err = apiRequest(nodeClient, nodeName, config.ChefVersion, "POST", "nodes", node, nil, nil, requests)
-			if err != nil {
-				node = chef.Node{Name: nodeName}
-			}
-		}
-	} else {
-		node = chef.Node{Name: nodeName}
-	}
-	node.Environment = chefEnvironment
-	node.AutomaticAttributes = ohaiJSON
-
-	if config.RunChefClient {
-		// Expand run_list
 		numLists := len(runLists)
 		rl := runList
 		if numLists > 0 {
 			rl = runLists[rand.Intn(numLists)]
 		}
-		expandedRunList = rl.expand(&nodeClient, nodeName, config.ChefVersion, chefEnvironment, requests)
-		apiRequest(nodeClient, nodeName, config.ChefVersion, "GET", "environments/"+chefEnvironment, nil, nil, nil, requests)
+		nodeRoles = rl.roleNames()
+		// Without role requests the roles of the run list aren't expanded
+		expandedRunList = rl.recipes()
+
+		// Load the node, expand its run_list and get its environment in the order of run_start_requests
+		for _, runStartRequest := range config.RunStartRequests {
+			switch runStartRequest {
+			case nodeRunStartRequest:
+				node = loadNode(nodeClient, nodeName, config.ChefVersion, chefEnvironment, requests)
+			case rolesRunStartRequest:
+				var err error
+				if usePolicy {
+					// Policy nodes get their run_list from the policy instead of their roles
+					nodePolicy, err = loadPolicy(&nodeClient, nodeName, config.ChefVersion, config.PolicyGroup, config.PolicyName, requests)
+					if err != nil {
+						log.WithFields(log.Fields{"node_name": nodeName, "error": err}).Warn("Could not load the policy, the chef-client run fails")
+						status = "failure"
+					} else {
+						runList = parseRunList(nodePolicy.RunList)
+						expandedRunList = runList.recipes()
+					}
+					break
+				}
+				expandedRunList, err = rl.expand(&nodeClient, nodeName, config.ChefVersion, chefEnvironment, nodeRoleCaches.node(nodeName, config.RoleCacheTTL), requests)
+				if err != nil {
+					log.WithFields(log.Fields{"node_name": nodeName, "error": err}).Warn("Could not expand the run_list, the chef-client run fails")
+					status = "failure"
+				}
+			case environmentRunStartRequest:
+				if usePolicy {
+					break
+				}
+				apiRequest(nodeClient, nodeName, config.ChefVersion, "GET", "environments/"+chefEnvironment, nil, nil, nil, requests)
+			}
+			if status == "failure" {
+				break
+			}
+		}
 
 		// Notify Reporting of run start
 		if config.EnableReporting {
@@ -153,6 +187,8 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 			}
 		}
 	}
+	node.Environment = chefEnvironment
+	node.AutomaticAttributes = ohaiJSON
 
 	// TODO: Check all the errors!
 	dataCollectorClient, _ := NewDataCollectorClient(&DataCollectorConfig{
@@ -180,30 +216,56 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 	}
 
 	if config.RunChefClient {
-		// Request resolved expanded runlist from the server
-		ckbks := solveRunListDependencies(&nodeClient, nodeName, config.ChefVersion, chefEnvironment, expandedRunList, requests)
-		// Download cookbooks
-		var dlCookbookFileChance = config.DownloadCookbooksScaleFactor
-		var doDownload = false
-		if config.DownloadCookbooks == "first" && firstRun {
-			doDownload = true
-			dlCookbookFileChance = 1.0
-		}
+		// A failed run_list expansion stops the run before the cookbooks are synchronized
+		if status != "failure" {
+			var ckbks cookbooks
+			if usePolicy {
+				ckbks = nodePolicy.cookbooks(&nodeClient, nodeName, config.ChefVersion, requests)
+			} else {
+				// Request resolved expanded runlist from the server
+				ckbks = solveRunListDependencies(&nodeClient, nodeName, config.ChefVersion, chefEnvironment, expandedRunList, requests)
+			}
+			// Download cookbooks
+			var dlCookbookFileChance = config.DownloadCookbooksScaleFactor
+			var doDownload = false
+			if config.DownloadCookbooks == "first" && firstRun {
+				doDownload = true
+				dlCookbookFileChance = 1.0
+			}
 
-		if doDownload || config.DownloadCookbooks == "always" {
-			ckbks.download(&nodeClient, nodeName, config.ChefVersion, dlCookbookFileChance, requests)
-		}
+			if doDownload || config.DownloadCookbooks == "always" {
+				ckbks.download(&nodeClient, nodeName, config, dlCookbookFileChance, requests)
+			} else if config.DownloadCookbooks == "cache" {
+				ckbks.downloadChanged(&nodeClient, nodeName, config, requests)
+			}
 
-		for _, apiGetRequest := range apiGetRequests {
-			apiRequest(nodeClient, nodeName, config.ChefVersion, "GET", apiGetRequest, nil, nil, nil, requests)
+			for _, apiGetRequest := range apiGetRequests {
+				apiRequest(nodeClient, nodeName, config.ChefVersion, "GET", apiGetRequest, nil, nil, nil, requests)
+			}
+			vars := templateVars{nodeName: nodeName, environment: chefEnvironment, roles: nodeRoles}
+			for _, cookbookRequest := range config.APIRequests {
+				if !cookbookRequest.request(nodeClient, nodeName, config.ChefVersion, vars, requests) {
+					status = "failure"
+				}
+			}
+			runWorkload(config.Workload, nodeClient, nodeName, config.ChefVersion, vars, requests)
 		}
 	} else {
 		expandedRunList = runList.toStringSlice()
 	}
 
-	time.Sleep(time.Duration(sleepDuration) * time.Second)
+	if config.ConvergeDuration.enabled() {
+		// The sampled converge time includes the requests of the run
+		time.Sleep(nodeConvergeDuration(config, nodeName) - time.Since(startTime))
+	} else {
+		time.Sleep(time.Duration(config.SleepDuration) * time.Second)
+	}
 
 	node.RunList = runList.toStringSlice()
+	if usePolicy {
+		node.PolicyName = config.PolicyName
+		node.PolicyGroup = config.PolicyGroup
+	}
 
 	// Ensure that at least an empty set of tags is set for the node's normal attributes
 	if node.NormalAttributes == nil {
@@ -218,13 +280,13 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 	node.AutomaticAttributes["ohai_time"] = endTime.Unix()
 
 	if config.RunChefClient {
-		if rand.Float64() <= config.NodeSaveFrequency {
-			apiRequest(nodeClient, nodeName, config.ChefVersion, "PUT", "nodes/"+nodeName, node, nil, nil, requests)
+		if status != "failure" && rand.Float64() <= config.NodeSaveFrequency {
+			apiRequest(nodeClient, nodeName, config.ChefVersion, "PUT", "nodes/"+nodeName, nodeSaveBody(config.ChefVersion, node), nil, nil, requests)
 		}
 
 		// Notify Reporting of run end
 		if config.EnableReporting && reportingAvailable {
-			reportingRunStop(nodeClient, nodeName, config.ChefVersion, runUUID, startTime, endTime, runList, requests)
+			reportingRunStop(config, nodeClient, nodeName, runUUID, startTime, endTime, status, runList, expandedRunList, requests)
 		}
 	}
 
@@ -242,6 +304,8 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 	ccrAction.SetTask(updateTask)
 	ccrAction.EntityName = nodeName
 	ccrAction.RequestorName = nodeName
+	ccrAction.RequestorType = "client"
+	ccrAction.setData(chefEnvironment, node.RunList)
 	if config.DataCollectorURL != "" {
 		chefAutomateSendMessage(dataCollectorClient, ccrAction.String(), ccrAction)
 	} else if dataCollectorAvailable {
@@ -258,3 +322,25 @@ func ChefClientRun(config *Config, nodeName string, firstRun bool, requests chan
 		}
 	}
 }
+
+// loadNode gets the node from the Chef Server and creates it when it doesn't exist yet
+func loadNode(nodeClient chef.Client, nodeName, chefVersion, chefEnvironment string, requests chan *request) chef.Node {
+	var node chef.Node
+	res, err := apiRequest(nodeClient, nodeName, chefVersion, "GET", "nodes/"+nodeName, nil, &node, nil, requests)
+	if err != nil {
+		if res != nil && res.StatusCode != 404 {
+			node = chef.Node{Name: nodeName}
+		}
+	}
+	if res != nil && res.StatusCode == 404 {
+		node = chef.Node{Name: nodeName, Environment: chefEnvironment}
+		_, err = apiRequest(nodeClient, nodeName, chefVersion, "POST", "nodes", node, nil, nil, requests)
+		if err != nil {
+			node = chef.Node{Name: nodeName}
+		}
+	}
+	if node.Name == "" {
+		node.Name = nodeName
+	}
+	return node
+}
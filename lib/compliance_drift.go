@@ -0,0 +1,260 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+import (
+	"math/rand"
+)
+
+const (
+	controlPassed  = "passed"
+	controlFailed  = "failed"
+	controlSkipped = "skipped"
+)
+
+// complianceDrift evolves the status of the controls of a node's sample report
+// from one scan to the next so that the history of a node isn't a flat line
+type complianceDrift struct {
+	config   Drift
+	statuses map[string]string
+	flapping map[string]bool
+}
+
+func newComplianceDrift(config Drift, sampleReport map[string]interface{}) *complianceDrift {
+	d := &complianceDrift{
+		config:   config,
+		statuses: map[string]string{},
+		flapping: map[string]bool{},
+	}
+	forEachControl(sampleReport, func(key string, control map[string]interface{}) {
+		d.statuses[key] = controlStatus(control)
+		if d.statuses[key] != controlSkipped && rand.Float64() < config.FlappingRate {
+			d.flapping[key] = true
+		}
+	})
+	return d
+}
+
+// next moves every control forward one scan: failed controls get fixed,
+// passed controls regress and flapping controls change at random
+func (d *complianceDrift) next() {
+	for key, status := range d.statuses {
+		switch {
+		case d.flapping[key]:
+			if rand.Float64() < 0.5 {
+				d.statuses[key] = controlFailed
+			} else {
+				d.statuses[key] = controlPassed
+			}
+		case status == controlFailed && rand.Float64() < d.config.FixRate:
+			d.statuses[key] = controlPassed
+		case status == controlPassed && rand.Float64() < d.config.RegressionRate:
+			d.statuses[key] = controlFailed
+		}
+	}
+}
+
+// report returns a copy of the sample report with the current control
+// statuses and its statistics and profile summaries recomputed
+func (d *complianceDrift) report(sampleReport map[string]interface{}) map[string]interface{} {
+	report := copyReport(sampleReport)
+
+	forEachControl(report, func(key string, control map[string]interface{}) {
+		status := d.statuses[key]
+		// Skips only last for a single scan
+		if status != controlSkipped && rand.Float64() < d.config.SkipRate {
+			status = controlSkipped
+		}
+		setControlStatus(control, status)
+	})
+
+	updateReportSummaries(report)
+	return report
+}
+
+// forEachControl calls fn with every control of the profiles of the report.
+// The controls of the "min" format are dropped before sending, so they are ignored
+func forEachControl(report map[string]interface{}, fn func(key string, control map[string]interface{})) {
+	profiles, _ := report["profiles"].([]interface{})
+	for _, p := range profiles {
+		profile, _ := p.(map[string]interface{})
+		controls, _ := profile["controls"].([]interface{})
+		for _, c := range controls {
+			if control, ok := c.(map[string]interface{}); ok {
+				fn(stringValue(profile["name"])+"/"+stringValue(control["id"]), control)
+			}
+		}
+	}
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// controlStatus derives the status of a control from its results like InSpec does
+func controlStatus(control map[string]interface{}) string {
+	results, _ := control["results"].([]interface{})
+	if len(results) == 0 {
+		return controlPassed
+	}
+	skipped := 0
+	for _, r := range results {
+		result, _ := r.(map[string]interface{})
+		switch result["status"] {
+		case controlFailed:
+			return controlFailed
+		case controlSkipped:
+			skipped++
+		}
+	}
+	if skipped == len(results) {
+		return controlSkipped
+	}
+	return controlPassed
+}
+
+// setControlStatus rewrites the results of the control so that it has the provided status
+func setControlStatus(control map[string]interface{}, status string) {
+	results, _ := control["results"].([]interface{})
+	for i, r := range results {
+		result, _ := r.(map[string]interface{})
+		if result == nil {
+			continue
+		}
+		delete(result, "message")
+		delete(result, "skip_message")
+		switch {
+		case status == controlSkipped:
+			result["status"] = controlSkipped
+			result["skip_message"] = "Skipped control due to only_if condition."
+		case status == controlFailed && i == 0:
+			result["status"] = controlFailed
+			result["message"] = "expected " + stringValue(result["code_desc"]) + " to pass"
+		default:
+			result["status"] = controlPassed
+		}
+	}
+}
+
+// updateReportSummaries recomputes the control totals of the report statistics
+// and the status of each profile
+func updateReportSummaries(report map[string]interface{}) {
+	totals := map[string]int{controlPassed: 0, controlFailed: 0, controlSkipped: 0}
+
+	profiles, _ := report["profiles"].([]interface{})
+	for _, p := range profiles {
+		profile, _ := p.(map[string]interface{})
+		if profile == nil {
+			continue
+		}
+		controls, _ := profile["controls"].([]interface{})
+		skipped := 0
+		for _, c := range controls {
+			control, _ := c.(map[string]interface{})
+			status := controlStatus(control)
+			totals[status]++
+			if status == controlSkipped {
+				skipped++
+			}
+		}
+
+		if len(controls) > 0 && skipped == len(controls) {
+			profile["status"] = "skipped"
+			profile["skip_message"] = "All the controls of the profile were skipped"
+		} else {
+			profile["status"] = "loaded"
+			delete(profile, "skip_message")
+		}
+	}
+
+	statistics := map[string]interface{}{}
+	if original, ok := report["statistics"].(map[string]interface{}); ok {
+		for k, v := range original {
+			statistics[k] = v
+		}
+	}
+	statistics["controls"] = map[string]interface{}{
+		"total":   totals[controlPassed] + totals[controlFailed] + totals[controlSkipped],
+		"passed":  map[string]int{"total": totals[controlPassed]},
+		"failed":  map[string]int{"total": totals[controlFailed]},
+		"skipped": map[string]int{"total": totals[controlSkipped]},
+	}
+	report["statistics"] = statistics
+}
+
+// copyReport copies the report deep enough to modify its profiles, controls
+// and results without modifying the original, everything else is shared
+func copyReport(report map[string]interface{}) map[string]interface{} {
+	reportCopy := copyMap(report)
+	profiles, _ := report["profiles"].([]interface{})
+	if profiles == nil {
+		return reportCopy
+	}
+
+	profilesCopy := make([]interface{}, len(profiles))
+	for i, p := range profiles {
+		profile, ok := p.(map[string]interface{})
+		if !ok {
+			profilesCopy[i] = p
+			continue
+		}
+		profileCopy := copyMap(profile)
+		controls, _ := profile["controls"].([]interface{})
+		if controls == nil {
+			profilesCopy[i] = profileCopy
+			continue
+		}
+		controlsCopy := make([]interface{}, len(controls))
+		for j, c := range controls {
+			control, ok := c.(map[string]interface{})
+			if !ok {
+				controlsCopy[j] = c
+				continue
+			}
+			controlCopy := copyMap(control)
+			results, _ := control["results"].([]interface{})
+			if results == nil {
+				controlsCopy[j] = controlCopy
+				continue
+			}
+			resultsCopy := make([]interface{}, len(results))
+			for k, r := range results {
+				if result, ok := r.(map[string]interface{}); ok {
+					resultsCopy[k] = copyMap(result)
+				} else {
+					resultsCopy[k] = r
+				}
+			}
+			controlCopy["results"] = resultsCopy
+			controlsCopy[j] = controlCopy
+		}
+		profileCopy["controls"] = controlsCopy
+		profilesCopy[i] = profileCopy
+	}
+	reportCopy["profiles"] = profilesCopy
+	return reportCopy
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,61 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleDriftReport() map[string]interface{} {
+	return map[string]interface{}{
+		"statistics": map[string]interface{}{"duration": 0.1},
+		"profiles": []interface{}{
+			map[string]interface{}{
+				"name": "linux-baseline",
+				"controls": []interface{}{
+					map[string]interface{}{"id": "c1", "results": []interface{}{
+						map[string]interface{}{"status": "failed", "code_desc": "a", "message": "nope"},
+						map[string]interface{}{"status": "passed", "code_desc": "b"},
+					}},
+					map[string]interface{}{"id": "c2", "results": []interface{}{
+						map[string]interface{}{"status": "passed", "code_desc": "c"},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func TestComplianceDriftFixesControls(t *testing.T) {
+	sample := sampleDriftReport()
+	drift := newComplianceDrift(Drift{Enabled: true, FixRate: 1.0}, sample)
+	drift.next()
+	report := drift.report(sample)
+
+	controls := report["statistics"].(map[string]interface{})["controls"].(map[string]interface{})
+	assert.Equal(t, 2, controls["total"])
+	assert.Equal(t, map[string]int{"total": 2}, controls["passed"])
+	assert.Equal(t, map[string]int{"total": 0}, controls["failed"])
+	assert.Equal(t, "loaded", report["profiles"].([]interface{})[0].(map[string]interface{})["status"])
+
+	// the sample report must not be modified
+	c1 := sample["profiles"].([]interface{})[0].(map[string]interface{})["controls"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, controlFailed, controlStatus(c1))
+	assert.Nil(t, sample["statistics"].(map[string]interface{})["controls"])
+}
+
+func TestComplianceDriftRegressesAndSkipsControls(t *testing.T) {
+	sample := sampleDriftReport()
+	drift := newComplianceDrift(Drift{Enabled: true, RegressionRate: 1.0}, sample)
+	drift.next()
+	report := drift.report(sample)
+
+	controls := report["statistics"].(map[string]interface{})["controls"].(map[string]interface{})
+	assert.Equal(t, map[string]int{"total": 2}, controls["failed"])
+
+	drift = newComplianceDrift(Drift{Enabled: true, SkipRate: 1.0}, sample)
+	report = drift.report(sample)
+	controls = report["statistics"].(map[string]interface{})["controls"].(map[string]interface{})
+	assert.Equal(t, map[string]int{"total": 2}, controls["skipped"])
+	assert.Equal(t, "skipped", report["profiles"].([]interface{})[0].(map[string]interface{})["status"])
+}
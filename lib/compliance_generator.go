@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"regexp"
+	"strconv"
 	"strings"
-
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-chef/chef"
 	"github.com/google/uuid"
-	"github.com/icrowley/fake"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -36,45 +39,215 @@ type NodeDetails struct {
 	chefTags    []string
 }
 
+// complianceProfile is an InSpec profile referenced in matrix.samples.platforms
+type complianceProfile struct {
+	name    string
+	version string
+}
+
+var profileVersionRE = regexp.MustCompile(`^(.+?)-(\d+\.\d+.*)$`)
+
+// parseComplianceProfile splits the profiles of the matrix samples
+// like "cis-centos6-level1-1.1.0-1.4" into name and version
+func parseComplianceProfile(profile string) complianceProfile {
+	match := profileVersionRE.FindStringSubmatch(profile)
+	if len(match) == 0 {
+		return complianceProfile{name: profile}
+	}
+	return complianceProfile{name: match[1], version: match[2]}
+}
+
+// matrixProfiles returns every distinct profile of the matrix samples
+func matrixProfiles(matrix *Matrix) []complianceProfile {
+	var (
+		profiles []complianceProfile
+		seen     = map[string]bool{}
+	)
+	if matrix == nil {
+		return profiles
+	}
+	for _, platform := range matrix.Samples.Platforms {
+		for _, profile := range platform.Profiles {
+			if !seen[profile] {
+				seen[profile] = true
+				profiles = append(profiles, parseComplianceProfile(profile))
+			}
+		}
+	}
+	return profiles
+}
+
+// Supported modes of compliance generation
+const (
+	// The nodes of matrix.simulation scanned at the frequencies of their bucket
+	simulationComplianceMode = "simulation"
+	// The node populations of matrix.statistics.sets scanned at their frequency
+	statisticsComplianceMode = "statistics"
+	// Every set of matrix.statistics.sets in turn, reporting the results of each
+	benchmarkComplianceMode = "benchmark"
+)
+
+// complianceNode is a node together with the minutes between its scans
+type complianceNode struct {
+	NodeDetails
+	interval int
+}
+
+// complianceResults summarizes the scans sent for a group of nodes
+type complianceResults struct {
+	nodes    int
+	scans    int64
+	ingested int64
+	rejected int64
+	elapsed  time.Duration
+}
+
 func GenerateComplianceData(config *Config, requests chan *request) error {
 	log.Infof("---> Load simulation config from matrix")
 	platforms := config.Matrix.Samples.Platforms
-	nodesCount := config.Matrix.Simulation.Nodes
 
-	log.Infof("generating %d nodes for %d platforms", nodesCount, len(platforms))
-	nodes := generateNodes(config.NodeNamePrefix, platforms, nodesCount)
-	log.Infof("nodes %v", nodes)
-	generateReports(config, nodes, requests)
+	switch config.Matrix.Simulation.Mode {
+	case statisticsComplianceMode:
+		nodes := statisticsNodes(config, 1, config.Matrix.Statistics.Sets)
+		log.Infof("generating %d nodes for %d platforms from %d statistics sets", len(nodes), len(platforms), len(config.Matrix.Statistics.Sets))
+		generateReports(config, nodes, requests)
+	case benchmarkComplianceMode:
+		sets := config.Matrix.Statistics.Sets
+		results := make([]complianceResults, len(sets))
+		firstIndex := 1
+		for i, set := range sets {
+			log.Infof("Benchmarking statistics set %d/%d: %d nodes scanned %d time(s) a day", i+1, len(sets), set.Nodes, set.ScanPerDay)
+			results[i] = generateReports(config, statisticsNodes(config, firstIndex, []Set{set}), requests)
+			firstIndex += set.Nodes
+		}
+		printComplianceBenchmark(sets, results)
+	default:
+		if config.UnifiedNodes {
+			log.Infof("generating the compliance reports of %d converge nodes for %d platforms", config.NumNodes, len(platforms))
+			generateReports(config, unifiedComplianceNodes(config), requests)
+			break
+		}
+		nodesCount := config.Matrix.Simulation.Nodes
+		log.Infof("generating %d nodes for %d platforms", nodesCount, len(platforms))
+		nodes := generateNodes(config, 1, nodesCount)
+		log.Infof("nodes %v", nodes)
+		generateReports(config, simulationNodes(config, nodes), requests)
+	}
 	return nil
 }
 
+// simulationNodes spreads the scan frequency of the nodes across the frequency buckets
+func simulationNodes(config *Config, nodes []NodeDetails) []complianceNode {
+	frequencies := scanFrequencies(config.Matrix.Simulation)
+	complianceNodes := make([]complianceNode, len(nodes))
+	for i, node := range nodes {
+		complianceNodes[i] = complianceNode{
+			NodeDetails: node,
+			interval:    intervalMinutes(len(nodes), i+1, frequencies),
+		}
+	}
+	return complianceNodes
+}
+
+// statisticsNodes generates the node population of every set, numbered from
+// firstIndex, each node is scanned scan_per_day times a day
+func statisticsNodes(config *Config, firstIndex int, sets []Set) []complianceNode {
+	var complianceNodes []complianceNode
+	for _, set := range sets {
+		for _, node := range generateNodes(config, firstIndex+len(complianceNodes), set.Nodes) {
+			complianceNodes = append(complianceNodes, complianceNode{
+				NodeDetails: node,
+				interval:    1440 / set.ScanPerDay,
+			})
+		}
+	}
+	return complianceNodes
+}
+
+func printComplianceBenchmark(sets []Set, results []complianceResults) {
+	log.Info("Printing compliance benchmark results")
+	log.Info(fmt.Sprintf("%-8s | %-8s | %-12s | %-10s | %-10s | %-10s | %-16s | %s",
+		"Set", "Nodes", "Scans/day", "Scans", "Ingested", "Rejected", "Elapsed", "Scans/second"))
+	for i, result := range results {
+		scansPerSecond := 0.0
+		if result.elapsed > 0 {
+			scansPerSecond = float64(result.scans) / result.elapsed.Seconds()
+		}
+		log.Info(fmt.Sprintf("%-8d   %-8d   %-12d   %-10d   %-10d   %-10d   %-16s   %.2f",
+			i+1, result.nodes, sets[i].ScanPerDay, result.scans, result.ingested, result.rejected,
+			result.elapsed.Round(time.Millisecond), scansPerSecond))
+	}
+}
+
 func int2ip(nn uint32) net.IP {
 	ip := make(net.IP, 4)
 	binary.BigEndian.PutUint32(ip, nn)
 	return ip
 }
 
-func generateNodeName(nodeNamePrefix string) string {
-	return strings.ToLower(fmt.Sprintf("%s-%s-%s-%s", nodeNamePrefix, fake.Color(), strings.Fields(fake.Street())[0], fake.Color()))
+const defaultNodeNameTemplate = "{prefix}-{words}-{index}"
+
+// generateNodeName fills the placeholders of the node name template:
+//
+//	{prefix}   - node_name_prefix
+//	{index}    - the number of the node, unique across the generated nodes
+//	{platform} - the platform of the node
+//	{words}    - a color-street-color combination derived from the index
+//
+// The name only depends on its arguments so that repeated runs address the same nodes
+func generateNodeName(template, nodeNamePrefix string, index int, platform string) string {
+	if template == "" {
+		template = defaultNodeNameTemplate
+	}
+	return strings.NewReplacer(
+		"{prefix}", nodeNamePrefix,
+		"{index}", strconv.Itoa(index),
+		"{platform}", platform,
+		"{words}", nodeNameWords(index),
+	).Replace(template)
 }
 
-func generateIpAddress() string {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+var (
+	nodeNameColors = []string{
+		"amber", "aqua", "azure", "beige", "black", "blue", "bronze", "coral",
+		"crimson", "cyan", "gold", "gray", "green", "indigo", "ivory", "khaki",
+		"lavender", "lime", "magenta", "maroon", "navy", "olive", "orange", "orchid",
+		"pink", "plum", "purple", "red", "salmon", "silver", "teal", "violet",
+		"white", "yellow",
+	}
+	nodeNameStreets = []string{
+		"acorn", "aspen", "birch", "brook", "cedar", "cherry", "chestnut", "church",
+		"elm", "forest", "garden", "hickory", "highland", "hill", "lake", "laurel",
+		"maple", "meadow", "mill", "oak", "park", "pine", "ridge", "river",
+		"spring", "spruce", "sunset", "valley", "walnut", "willow",
+	}
+)
+
+// nodeNameWords returns the words of the name of the node, they come from a
+// source of its own seeded with the index so that concurrent callers always
+// get the same words
+func nodeNameWords(index int) string {
+	r := rand.New(rand.NewSource(int64(index)))
+	return fmt.Sprintf("%s-%s-%s",
+		nodeNameColors[r.Intn(len(nodeNameColors))],
+		nodeNameStreets[r.Intn(len(nodeNameStreets))],
+		nodeNameColors[r.Intn(len(nodeNameColors))])
+}
+
+func generateIpAddress(r *rand.Rand) string {
 	return fmt.Sprintf("%d.%d.%d.%d", r.Intn(255), r.Intn(255), r.Intn(255), r.Intn(255))
 }
 
-func generateSourcFqdn() string {
-	rand.Seed(time.Now().UnixNano())
+func generateSourcFqdn(r *rand.Rand) string {
 	data := []string{
 		"chefserver1.foo.bar",
 		"alex.kung.foo.arm.bar",
 		"rick.kung.foo.arm.bar",
 	}
-	return data[rand.Intn(len(data))]
+	return data[r.Intn(len(data))]
 }
 
-func generateChefOrgs() string {
-	rand.Seed(time.Now().UnixNano())
+func generateChefOrgs(r *rand.Rand) string {
 	data := []string{
 		"org1",
 		"org2",
@@ -87,11 +260,10 @@ func generateChefOrgs() string {
 		"org9",
 		"org10",
 	}
-	return data[rand.Intn(len(data))]
+	return data[r.Intn(len(data))]
 }
 
-func generateChefTags() []string {
-	rand.Seed(time.Now().UnixNano())
+func generateChefTags(r *rand.Rand) []string {
 	data := []string{
 		"tag1",
 		"tag2",
@@ -105,49 +277,50 @@ func generateChefTags() []string {
 		"tag10",
 	}
 	//shuffle the list of tags and then return a random number of them
-	rand.Shuffle(len(data), func(i, j int) { data[i], data[j] = data[j], data[i] })
-	return data[0:rand.Intn(len(data))]
+	r.Shuffle(len(data), func(i, j int) { data[i], data[j] = data[j], data[i] })
+	return data[0:r.Intn(len(data))]
 }
 
-func generatePolicyGroup() string {
-	rand.Seed(time.Now().UnixNano())
+func generatePolicyGroup(r *rand.Rand) string {
 	data := []string{
 		"policy.group1",
 		"policy.group2",
 		"policy.group3",
 		"policy.group4",
 	}
-	return data[rand.Intn(len(data))]
+	return data[r.Intn(len(data))]
 }
 
-func generatePolicyName() string {
-	rand.Seed(time.Now().UnixNano())
+func generatePolicyName(r *rand.Rand) string {
 	data := []string{
 		"policy.name1",
 		"policy.name2",
 		"policy.name3",
 		"policy.name4",
 	}
-	return data[rand.Intn(len(data))]
+	return data[r.Intn(len(data))]
 }
 
-func generateNodes(nodeNamePrefix string, platforms []Platform, nodesCount int) (nodes []NodeDetails) {
-
-	// add missing nodes until we have enough
-	for len(nodes) < nodesCount {
+// generateNodes generates nodesCount nodes, numbered from firstIndex, spread
+// over the platforms in turn. Every detail of a node only depends on its
+// number so that repeated runs report the same nodes
+func generateNodes(config *Config, firstIndex int, nodesCount int) (nodes []NodeDetails) {
+	platforms := config.Matrix.Samples.Platforms
+	for index := firstIndex; index < firstIndex+nodesCount; index++ {
+		platform := platforms[(index-1)%len(platforms)].Name
+		r := rand.New(rand.NewSource(int64(index)))
 		node := NodeDetails{
-			// TODO: we can have multiple nodes with the same node name
-			name:        generateNodeName(nodeNamePrefix),
-			ipAddr:      generateIpAddress(),
-			sourceFqdn:  generateSourcFqdn(),
-			environment: getRandom(compEnvironments),
-			roles:       getRandomStringArray(compRoles),
-			recipes:     getRandomStringArray(compRecipes),
-			orgName:     generateChefOrgs(),
-			chefTags:    generateChefTags(),
-			policyGroup: generatePolicyGroup(),
-			policyName:  generatePolicyName(),
-			platform:    platforms[rand.Intn(len(platforms))].Name,
+			name:        generateNodeName(config.Matrix.Simulation.NodeNameTemplate, config.NodeNamePrefix, index, platform),
+			ipAddr:      generateIpAddress(r),
+			sourceFqdn:  generateSourcFqdn(r),
+			environment: complianceEnv[r.Intn(len(complianceEnv))],
+			roles:       complianceRoles[r.Intn(len(complianceRoles))],
+			recipes:     complianceRecipes[r.Intn(len(complianceRecipes))],
+			orgName:     generateChefOrgs(r),
+			chefTags:    generateChefTags(r),
+			policyGroup: generatePolicyGroup(r),
+			policyName:  generatePolicyName(r),
+			platform:    platform,
 		}
 		node.fqdn = node.name
 		node.nodeUUID = uuid.NewMD5(uuid.NameSpaceDNS, []byte(node.name))
@@ -156,23 +329,45 @@ func generateNodes(nodeNamePrefix string, platforms []Platform, nodesCount int)
 	return nodes
 }
 
-func intervalMinutes(nodesCount int, index int, maxScansPerDay int) int {
-	divisor := float32(index) / float32(nodesCount)
+// scanFrequencies returns the configured frequency buckets of the simulation,
+// by default 10% of the nodes are scanned max_scans times a day, 30% daily,
+// 40% weekly and 20% monthly
+func scanFrequencies(simulation Simulation) []Frequency {
+	if len(simulation.Frequencies) > 0 {
+		return simulation.Frequencies
+	}
+	return []Frequency{
+		{Fraction: 0.1, Interval: 1440 / simulation.MaxScans},
+		{Fraction: 0.3, Interval: 1440},
+		{Fraction: 0.4, Interval: 10080},
+		{Fraction: 0.2, Interval: 43200},
+	}
+}
+
+// intervalMinutes returns the interval of the bucket that the node at index
+// (starting at 1) falls into, the fractions of the buckets are relative to their sum
+func intervalMinutes(nodesCount int, index int, frequencies []Frequency) int {
+	total := 0.0
+	for _, frequency := range frequencies {
+		total += frequency.Fraction
+	}
 
-	if divisor <= .1 { //10%
-		return 1440 / maxScansPerDay
-	} else if divisor <= .4 { //30%
-		return 1440
-	} else if divisor <= .8 { //40%
-		return 10080
-	} else {
-		return 43200
+	position := float64(index) / float64(nodesCount)
+	cumulative := 0.0
+	for _, frequency := range frequencies {
+		cumulative += frequency.Fraction / total
+		if position <= cumulative {
+			return frequency.Interval
+		}
 	}
+	return frequencies[len(frequencies)-1].Interval
 }
 
 func intervalToString(minutes int) string {
 	hours := minutes / 60
-	if hours < 24 {
+	if hours == 0 {
+		return fmt.Sprintf("%d minute(s)", minutes)
+	} else if hours < 24 {
 		return fmt.Sprintf("%d hour(s)", hours)
 	} else {
 		return fmt.Sprintf("%d day(s)", hours/24)
@@ -184,48 +379,145 @@ func loadSampleReport(config *Config, platform string, format string) map[string
 	return complianceJSON
 }
 
-//func generate_reports(nodes []NodeDetails, platforms []string, simulation, handler) {
-func generateReports(config *Config, nodes []NodeDetails, requests chan *request) {
-	endTime := time.Now().UTC()
-
-	dataCollectorClient, _ := NewDataCollectorClient(&DataCollectorConfig{
-		Token:   config.DataCollectorToken,
-		URL:     config.DataCollectorURL,
-		SkipSSL: true,
-	}, requests)
-
-	var nodesCount = config.Matrix.Simulation.Nodes
-	if nodesCount < len(nodes) {
-		nodesCount = len(nodes)
-	}
-
-	log.Infof("Generating Inspec reports over a period of %d day(s)", config.Matrix.Simulation.Days)
-	totalScans := 0
-	nodeIndex := 0
-	totalMaxScans := config.Matrix.Simulation.TotalMaxScans
-	for nodeIndex < nodesCount && totalScans < totalMaxScans {
-		node := nodes[nodeIndex]
-		sampleReport := loadSampleReport(config, node.platform, config.Matrix.Simulation.SampleFormat)
-		interval := intervalMinutes(nodesCount, nodeIndex+1, config.Matrix.Simulation.MaxScans)
-		log.Infof("Generating Inspec reports for node %s (%d/%d) with interval of %s , scans so far: %d", node.name, nodeIndex+1, nodesCount, intervalToString(interval), totalScans)
-		maxScansNode := (config.Matrix.Simulation.Days*24*60)/interval + 1
-		scanIndex := maxScansNode
-		for scanIndex > 0 && totalScans < totalMaxScans {
-			scanIndex -= 1
-			report := sampleReport
-			reportUUID := uuid.New()
-			reportEndTime := endTime.Add(time.Duration(-interval*scanIndex) * time.Minute)
-			complianceReportBody := dataCollectorComplianceReport(node, reportUUID, reportEndTime, report)
-
-			if config.DataCollectorURL != "" {
-				chefAutomateSendMessage(dataCollectorClient, node.name, complianceReportBody)
+// sampleReport returns the report that the scans of the node are based on, it is
+// either generated from the [matrix.synthetic] spec or loaded from the sample reports
+func sampleReport(config *Config, platformName string) map[string]interface{} {
+	if config.Matrix.Synthetic.Enabled {
+		platform := Platform{Name: platformName}
+		for _, p := range config.Matrix.Samples.Platforms {
+			if p.Name == platformName {
+				platform = p
+			}
+		}
+		return generateSyntheticReport(config.Matrix.Synthetic, platform)
+	}
+	return loadSampleReport(config, platformName, config.Matrix.Simulation.SampleFormat)
+}
+
+// complianceDelivery sends the compliance reports like the chef-client runs do,
+// directly to Automate or through the data-collector endpoint of the Chef Server
+type complianceDelivery struct {
+	config              *Config
+	dataCollectorClient *DataCollectorClient
+	chefClient          *chef.Client
+	requests            chan *request
+}
+
+func newComplianceDelivery(config *Config, requests chan *request) *complianceDelivery {
+	d := &complianceDelivery{config: config, requests: requests}
+	if config.DataCollectorURL != "" {
+		d.dataCollectorClient, _ = NewDataCollectorClient(&DataCollectorConfig{
+			Token:   config.DataCollectorToken,
+			URL:     config.DataCollectorURL,
+			SkipSSL: true,
+			Gzip:    config.Matrix.Scaling.Gzip,
+		}, requests)
+	} else if config.RunChefClient {
+		chefClient := getAPIClient(config.ClientName, config.ClientKey, config.ChefServerURL)
+		d.chefClient = &chefClient
+	}
+	return d
+}
+
+// enabled returns false when there is nowhere to send the reports to
+func (d *complianceDelivery) enabled() bool {
+	return d.dataCollectorClient != nil || d.chefClient != nil
+}
+
+// send returns the status code of the response, 999 when there is none
+func (d *complianceDelivery) send(nodeName string, body interface{}) int {
+	if d.dataCollectorClient != nil {
+		code, _ := chefAutomateSendMessage(d.dataCollectorClient, nodeName, body)
+		return code
+	}
+	res, _ := apiRequest(*d.chefClient, nodeName, d.config.ChefVersion, "POST", "data-collector", body, nil, nil, d.requests)
+	if res == nil {
+		return 999
+	}
+	return res.StatusCode
+}
+
+// generateReports sends the scans of the nodes with a pool of workers
+func generateReports(config *Config, nodes []complianceNode, requests chan *request) complianceResults {
+	var (
+		endTime     = time.Now().UTC()
+		startTime   = time.Now()
+		jobs, total = complianceJobs(config.Matrix.Simulation, nodes)
+		concurrency = complianceConcurrency(config)
+		pool        = newCompliancePool(config)
+		jobsChan    = make(chan complianceJob)
+		done        = make(chan struct{})
+		wg          sync.WaitGroup
+	)
+	defer pool.stop()
+
+	delivery := newComplianceDelivery(config, requests)
+
+	log.WithFields(log.Fields{
+		"nodes":       len(jobs),
+		"scans":       total,
+		"days":        config.Matrix.Simulation.Days,
+		"concurrency": concurrency,
+	}).Info("Generating Inspec reports")
+	go pool.logProgress(total, startTime, done)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobsChan {
+				generateNodeReports(config, job, endTime, delivery, pool)
 			}
+		}()
+	}
+	for i, job := range jobs {
+		log.Debugf("Generating Inspec reports for node %s (%d/%d) with interval of %s", job.node.name, i+1, len(jobs), intervalToString(job.node.interval))
+		jobsChan <- job
+	}
+	close(jobsChan)
+	wg.Wait()
+	close(done)
+
+	return complianceResults{
+		nodes:    len(nodes),
+		scans:    pool.scans,
+		ingested: pool.ingested,
+		rejected: pool.rejected,
+		elapsed:  time.Since(startTime),
+	}
+}
 
-			if scanIndex > 0 && scanIndex%500 == 0 {
-				log.Info(scanIndex)
+// generateNodeReports sends the scans of a node in order, the last one ends at endTime
+func generateNodeReports(config *Config, job complianceJob, endTime time.Time, delivery *complianceDelivery, pool *compliancePool) {
+	node := job.node
+	interval := node.interval
+	sampleReport := scaleReport(config.Matrix.Scaling, sampleReport(config, node.platform))
+	var drift *complianceDrift
+	if config.Matrix.Drift.Enabled {
+		drift = newComplianceDrift(config.Matrix.Drift, sampleReport)
+	}
+
+	for scanIndex := job.scans - 1; scanIndex >= 0; scanIndex-- {
+		report := sampleReport
+		if drift != nil {
+			drift.next()
+			report = drift.report(sampleReport)
+		}
+		reportUUID := uuid.New()
+		reportEndTime := endTime.Add(time.Duration(-interval*scanIndex) * time.Minute)
+		if jitterFraction := config.Matrix.Simulation.Jitter; jitterFraction > 0 {
+			intervalDuration := time.Duration(interval) * time.Minute
+			reportEndTime = reportEndTime.Add(jitter(intervalDuration, jitterFraction) - intervalDuration)
+			if reportEndTime.After(endTime) {
+				reportEndTime = endTime
 			}
 		}
-		totalScans += maxScansNode
-		nodeIndex += 1
+		complianceReportBody := dataCollectorComplianceReport(node.NodeDetails, reportUUID, reportEndTime, report)
+
+		if delivery.enabled() {
+			pool.wait()
+			pool.record(delivery.send(node.name, complianceReportBody))
+		}
+		atomic.AddInt64(&pool.scans, 1)
 	}
 }
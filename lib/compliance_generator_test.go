@@ -9,8 +9,83 @@ import (
 )
 
 func TestGenerateNodeName(t *testing.T) {
-	nodeName := generateNodeName("prefix")
+	nodeName := generateNodeName(defaultNodeNameTemplate, "prefix", 7, "centos6")
 
 	nodeNameTokenized := strings.Split(nodeName, "-")
-	assert.Len(t, nodeNameTokenized, 4, "")
+	assert.Len(t, nodeNameTokenized, 5, "")
+	assert.Equal(t, "7", nodeNameTokenized[4])
+	assert.Equal(t, nodeName, generateNodeName(defaultNodeNameTemplate, "prefix", 7, "centos6"))
+	assert.Equal(t, "prefix-centos6-7", generateNodeName("{prefix}-{platform}-{index}", "prefix", 7, "centos6"))
+}
+
+func TestGenerateNodesUnique(t *testing.T) {
+	config := Default()
+	nodes := statisticsNodes(&config, 1, []Set{{Nodes: 50, ScanPerDay: 1}, {Nodes: 50, ScanPerDay: 2}})
+
+	names := map[string]bool{}
+	for _, node := range nodes {
+		names[node.name] = true
+	}
+	assert.Len(t, names, 100)
+	// Every detail of a node is the same on every run
+	assert.Equal(t, nodes[3].NodeDetails, generateNodes(&config, 4, 1)[0])
+}
+
+func TestParseComplianceProfile(t *testing.T) {
+	assert.Equal(t, complianceProfile{name: "cis-centos6-level1", version: "1.1.0-1.4"}, parseComplianceProfile("cis-centos6-level1-1.1.0-1.4"))
+	assert.Equal(t, complianceProfile{name: "ssh-baseline", version: "2.2.0"}, parseComplianceProfile("ssh-baseline-2.2.0"))
+	assert.Equal(t, complianceProfile{name: "cis-ubuntu12_04lts-level1", version: "1.1.0-2"}, parseComplianceProfile("cis-ubuntu12_04lts-level1-1.1.0-2"))
+	assert.Equal(t, complianceProfile{name: "no-version"}, parseComplianceProfile("no-version"))
+}
+
+func TestStatisticsNodes(t *testing.T) {
+	config := Default()
+	nodes := statisticsNodes(&config, 1, []Set{{Nodes: 2, ScanPerDay: 1}, {Nodes: 3, ScanPerDay: 96}})
+
+	assert.Len(t, nodes, 5)
+	assert.Equal(t, 1440, nodes[0].interval)
+	assert.Equal(t, 15, nodes[4].interval)
+	assert.Equal(t, "15 minute(s)", intervalToString(nodes[4].interval))
+}
+
+func TestIntervalMinutes(t *testing.T) {
+	defaults := scanFrequencies(Simulation{MaxScans: 2})
+	assert.Equal(t, 720, intervalMinutes(10, 1, defaults))
+	assert.Equal(t, 1440, intervalMinutes(10, 4, defaults))
+	assert.Equal(t, 10080, intervalMinutes(10, 8, defaults))
+	assert.Equal(t, 43200, intervalMinutes(10, 10, defaults))
+
+	frequencies := []Frequency{{Fraction: 1, Interval: 60}, {Fraction: 3, Interval: 240}}
+	assert.Equal(t, frequencies, scanFrequencies(Simulation{MaxScans: 2, Frequencies: frequencies}))
+	assert.Equal(t, 60, intervalMinutes(4, 1, frequencies))
+	assert.Equal(t, 240, intervalMinutes(4, 2, frequencies))
+	assert.Equal(t, 240, intervalMinutes(4, 4, frequencies))
+}
+
+func TestComplianceJobs(t *testing.T) {
+	nodes := []complianceNode{{interval: 1440}, {interval: 720}, {interval: 1440}}
+
+	jobs, total := complianceJobs(Simulation{Days: 2, TotalMaxScans: 100}, nodes)
+	assert.Len(t, jobs, 3)
+	assert.Equal(t, []int{3, 5, 3}, []int{jobs[0].scans, jobs[1].scans, jobs[2].scans})
+	assert.Equal(t, int64(11), total)
+
+	jobs, total = complianceJobs(Simulation{Days: 2, TotalMaxScans: 6}, nodes)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, 3, jobs[1].scans)
+	assert.Equal(t, int64(6), total)
+}
+
+func TestNewCompliancePoolLimiter(t *testing.T) {
+	config := Default()
+	config.Matrix.Simulation.ScansPerSecond = 10
+	pool := newCompliancePool(&config)
+	assert.NotNil(t, pool.limiter)
+	pool.stop()
+
+	// Rates above the ticker resolution don't panic, they aren't limited
+	config.Matrix.Simulation.ScansPerSecond = 1e10
+	pool = newCompliancePool(&config)
+	assert.Nil(t, pool.limiter)
+	pool.stop()
 }
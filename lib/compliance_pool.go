@@ -0,0 +1,165 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+// This file runs the compliance scans of the nodes with a pool of workers
+// that share a rate limit and back off together when reports are rejected
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// How often the progress of the compliance generation is logged
+const complianceProgressInterval = 10 * time.Second
+
+// complianceJob is a node and the number of scans to send for it, the scans
+// of a node are sent in order by a single worker so that drift stays consistent
+type complianceJob struct {
+	node  complianceNode
+	scans int
+}
+
+// complianceJobs plans the scans of every node over the simulated days
+// without going over total_max_scans
+func complianceJobs(simulation Simulation, nodes []complianceNode) ([]complianceJob, int64) {
+	var (
+		jobs       []complianceJob
+		totalScans int64
+		maxScans   = int64(simulation.TotalMaxScans)
+	)
+	for _, node := range nodes {
+		if totalScans >= maxScans {
+			break
+		}
+		scans := int64((simulation.Days*24*60)/node.interval + 1)
+		if totalScans+scans > maxScans {
+			scans = maxScans - totalScans
+		}
+		jobs = append(jobs, complianceJob{node: node, scans: int(scans)})
+		totalScans += scans
+	}
+	return jobs, totalScans
+}
+
+// complianceConcurrency returns the number of workers that send the reports,
+// matrix.simulation.concurrency or the threads setting when it isn't set
+func complianceConcurrency(config *Config) int {
+	if config.Matrix.Simulation.Concurrency > 0 {
+		return config.Matrix.Simulation.Concurrency
+	}
+	if config.Threads > 0 {
+		return config.Threads
+	}
+	return 1
+}
+
+// compliancePool holds the state shared by the workers
+type compliancePool struct {
+	limiter       *time.Ticker
+	sleepOnReject time.Duration
+
+	mu           sync.Mutex
+	backoffUntil time.Time
+
+	scans    int64
+	ingested int64
+	rejected int64
+}
+
+func newCompliancePool(config *Config) *compliancePool {
+	p := &compliancePool{
+		sleepOnReject: time.Duration(config.SleepTimeOnFailure) * time.Second,
+	}
+	if scansPerSecond := config.Matrix.Simulation.ScansPerSecond; scansPerSecond > 0 {
+		// Rates above the resolution of the ticker can't be limited, they run unlimited
+		if interval := time.Duration(float64(time.Second) / scansPerSecond); interval > 0 {
+			p.limiter = time.NewTicker(interval)
+		}
+	}
+	return p
+}
+
+func (p *compliancePool) stop() {
+	if p.limiter != nil {
+		p.limiter.Stop()
+	}
+}
+
+// wait blocks until the worker is allowed to send the next report
+func (p *compliancePool) wait() {
+	p.mu.Lock()
+	backoff := time.Until(p.backoffUntil)
+	p.mu.Unlock()
+	if backoff > 0 {
+		time.Sleep(backoff)
+	}
+	if p.limiter != nil {
+		<-p.limiter.C
+	}
+}
+
+// record counts the response of a sent report, a rejection makes every
+// worker sleep sleep_time_on_failure seconds to let the system digest
+func (p *compliancePool) record(code int) {
+	if code >= 200 && code <= 299 {
+		atomic.AddInt64(&p.ingested, 1)
+		return
+	}
+	atomic.AddInt64(&p.rejected, 1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Now().After(p.backoffUntil) && p.sleepOnReject > 0 {
+		p.backoffUntil = time.Now().Add(p.sleepOnReject)
+		log.WithFields(log.Fields{
+			"status_code": code,
+			"sleep":       p.sleepOnReject,
+		}).Info("Compliance report rejected, sleeping")
+	}
+}
+
+// logProgress logs the scans sent so far and the estimated time left until done is closed
+func (p *compliancePool) logProgress(totalScans int64, startTime time.Time, done <-chan struct{}) {
+	ticker := time.NewTicker(complianceProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			scans := atomic.LoadInt64(&p.scans)
+			elapsed := time.Since(startTime)
+			fields := log.Fields{
+				"scans":    fmt.Sprintf("%d/%d", scans, totalScans),
+				"ingested": atomic.LoadInt64(&p.ingested),
+				"rejected": atomic.LoadInt64(&p.rejected),
+				"elapsed":  elapsed.Round(time.Second),
+			}
+			if scans > 0 {
+				fields["scans_per_second"] = fmt.Sprintf("%.2f", float64(scans)/elapsed.Seconds())
+				fields["eta"] = (time.Duration(float64(elapsed) / float64(scans) * float64(totalScans-scans))).Round(time.Second)
+			}
+			log.WithFields(fields).Info("Generating Inspec reports")
+		}
+	}
+}
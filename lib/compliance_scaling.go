@@ -0,0 +1,136 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+// This file grows the InSpec reports to the [matrix.scaling] targets so that
+// chef-load can stress the payload size limits of Automate
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// profileControl is a control of the report together with the profile that holds it
+type profileControl struct {
+	profile map[string]interface{}
+	control map[string]interface{}
+}
+
+// scaleReport returns a copy of the "full" report with the results of every
+// control repeated up to results_per_control and its controls repeated until
+// the report has target_controls controls and is target_bytes long
+func scaleReport(spec Scaling, report map[string]interface{}) map[string]interface{} {
+	if spec.TargetBytes <= 0 && spec.TargetControls <= 0 && spec.ResultsPerControl <= 0 {
+		return report
+	}
+	report = copyReport(report)
+
+	var controls []profileControl
+	profiles, _ := report["profiles"].([]interface{})
+	for _, p := range profiles {
+		profile, _ := p.(map[string]interface{})
+		profileControls, _ := profile["controls"].([]interface{})
+		for _, c := range profileControls {
+			if control, ok := c.(map[string]interface{}); ok {
+				if spec.ResultsPerControl > 0 {
+					repeatResults(control, spec.ResultsPerControl)
+				}
+				controls = append(controls, profileControl{profile: profile, control: control})
+			}
+		}
+	}
+	if len(controls) == 0 {
+		return report
+	}
+
+	total := len(controls)
+	addControls := func(count int) {
+		for i := 0; i < count; i++ {
+			original := controls[total%len(controls)]
+			controlCopy := copyControl(original.control)
+			controlCopy["id"] = fmt.Sprintf("%s-copy-%d", stringValue(original.control["id"]), total/len(controls))
+			profileControls, _ := original.profile["controls"].([]interface{})
+			original.profile["controls"] = append(profileControls, controlCopy)
+			total++
+		}
+	}
+
+	if spec.TargetControls > total {
+		addControls(spec.TargetControls - total)
+	}
+	for spec.TargetBytes > 0 {
+		size := reportSize(report)
+		if size == 0 || size >= spec.TargetBytes {
+			break
+		}
+		// Grow in proportion to the bytes that are still missing, at least by one control
+		missing := (spec.TargetBytes - size) * total / size
+		if missing < 1 {
+			missing = 1
+		}
+		addControls(missing)
+	}
+
+	updateReportSummaries(report)
+	return report
+}
+
+// repeatResults copies the results of the control until it has count of them
+func repeatResults(control map[string]interface{}, count int) {
+	results, _ := control["results"].([]interface{})
+	originals := len(results)
+	if originals == 0 {
+		return
+	}
+	for i := originals; i < count; i++ {
+		result, ok := results[i%originals].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resultCopy := copyMap(result)
+		resultCopy["code_desc"] = fmt.Sprintf("%s (copy %d)", stringValue(result["code_desc"]), i/originals)
+		results = append(results, resultCopy)
+	}
+	control["results"] = results
+}
+
+// copyControl copies the control and its results
+func copyControl(control map[string]interface{}) map[string]interface{} {
+	controlCopy := copyMap(control)
+	if results, ok := control["results"].([]interface{}); ok {
+		resultsCopy := make([]interface{}, len(results))
+		for i, r := range results {
+			if result, ok := r.(map[string]interface{}); ok {
+				resultsCopy[i] = copyMap(result)
+			} else {
+				resultsCopy[i] = r
+			}
+		}
+		controlCopy["results"] = resultsCopy
+	}
+	return controlCopy
+}
+
+// reportSize returns the length of the report in JSON
+func reportSize(report map[string]interface{}) int {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return 0
+	}
+	return len(reportJSON)
+}
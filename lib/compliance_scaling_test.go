@@ -0,0 +1,31 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaleReport(t *testing.T) {
+	spec := Synthetic{ControlsPerProfile: 5, ResultsPerControl: 1, CodeSize: 100}
+	report := generateSyntheticReport(spec, Platform{Name: "centos6", Profiles: []string{"linux-baseline-2.2.0"}})
+
+	assert.Equal(t, report, scaleReport(Scaling{}, report))
+
+	scaled := scaleReport(Scaling{TargetControls: 12, ResultsPerControl: 3}, report)
+	var ids []string
+	forEachControl(scaled, func(key string, control map[string]interface{}) {
+		ids = append(ids, key)
+		assert.Len(t, control["results"], 3)
+	})
+	assert.Len(t, ids, 12)
+	assert.Contains(t, ids, "linux-baseline/linux-baseline-1-copy-1")
+	assert.Equal(t, 12, scaled["statistics"].(map[string]interface{})["controls"].(map[string]interface{})["total"])
+
+	// The sample report is left as it was
+	controls := 0
+	forEachControl(report, func(string, map[string]interface{}) { controls++ })
+	assert.Equal(t, 5, controls)
+
+	assert.True(t, reportSize(scaleReport(Scaling{TargetBytes: 100000}, report)) >= 100000)
+}
@@ -0,0 +1,220 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+// This file generates InSpec reports from the [matrix.synthetic] spec so that
+// compliance load doesn't depend on the sample reports
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// The impact range of each InSpec severity
+var impactRanges = map[string][2]float64{
+	"critical": {0.9, 1.0},
+	"high":     {0.7, 0.9},
+	"medium":   {0.4, 0.7},
+	"low":      {0.1, 0.4},
+	"none":     {0.0, 0.0},
+}
+
+// Ordered list of the severities so that the impact choice is stable
+var impactSeverities = []string{"critical", "high", "medium", "low", "none"}
+
+// syntheticProfiles returns the profiles of the report: the profiles of the
+// platform first and then made up ones until there are spec.Profiles of them
+func syntheticProfiles(spec Synthetic, platform Platform) []complianceProfile {
+	var profiles []complianceProfile
+	for _, profile := range platform.Profiles {
+		profiles = append(profiles, parseComplianceProfile(profile))
+	}
+	if spec.Profiles > 0 && len(profiles) > spec.Profiles {
+		profiles = profiles[:spec.Profiles]
+	}
+	for i := len(profiles) + 1; i <= spec.Profiles; i++ {
+		profiles = append(profiles, complianceProfile{name: fmt.Sprintf("synthetic-profile-%d", i), version: "1.0.0"})
+	}
+	for i := range profiles {
+		if profiles[i].version == "" {
+			profiles[i].version = "1.0.0"
+		}
+	}
+	return profiles
+}
+
+func generateSyntheticReport(spec Synthetic, platform Platform) map[string]interface{} {
+	var (
+		now      = time.Now().UTC()
+		profiles = []interface{}{}
+		duration = 0.0
+	)
+
+	for _, profile := range syntheticProfiles(spec, platform) {
+		controls := make([]interface{}, spec.ControlsPerProfile)
+		controlIDs := make([]interface{}, spec.ControlsPerProfile)
+		for i := range controls {
+			controlID := fmt.Sprintf("%s-%d", profile.name, i+1)
+			controlIDs[i] = controlID
+			control := syntheticControl(spec, controlID, now)
+			for _, r := range control["results"].([]interface{}) {
+				duration += r.(map[string]interface{})["run_time"].(float64)
+			}
+			controls[i] = control
+		}
+
+		profiles = append(profiles, map[string]interface{}{
+			"name":            profile.name,
+			"version":         profile.version,
+			"sha256":          fmt.Sprintf("%x", sha256.Sum256([]byte(profile.name+"-"+profile.version))),
+			"title":           "Synthetic " + profile.name + " profile",
+			"maintainer":      "chef-load",
+			"summary":         "Synthetic profile generated by chef-load",
+			"license":         "Apache-2.0",
+			"copyright":       "Chef Software, Inc.",
+			"copyright_email": "support@chef.io",
+			"supports":        []interface{}{map[string]interface{}{"platform": platform.Name}},
+			"attributes":      []interface{}{},
+			"groups": []interface{}{map[string]interface{}{
+				"id":       "controls/synthetic.rb",
+				"title":    profile.name,
+				"controls": controlIDs,
+			}},
+			"status":   "loaded",
+			"controls": controls,
+		})
+	}
+
+	return map[string]interface{}{
+		"version": "4.18.24",
+		"platform": map[string]interface{}{
+			"name":    platform.Name,
+			"release": "1.0",
+		},
+		"profiles":     profiles,
+		"other_checks": []interface{}{},
+		"statistics":   map[string]interface{}{"duration": duration},
+	}
+}
+
+func syntheticControl(spec Synthetic, controlID string, startTime time.Time) map[string]interface{} {
+	var (
+		results = make([]interface{}, spec.ResultsPerControl)
+		tags    = map[string]interface{}{}
+		refs    = make([]interface{}, spec.RefsPerControl)
+	)
+
+	waived := rand.Float64() < spec.WaiverRate
+	for i := range results {
+		result := map[string]interface{}{
+			"code_desc":  fmt.Sprintf("%s check %d should be compliant", controlID, i+1),
+			"run_time":   rand.Float64() / 100,
+			"start_time": startTime.Format(time.RFC3339),
+		}
+		switch r := rand.Float64(); {
+		case waived:
+			result["status"] = controlSkipped
+			result["skip_message"] = "Skipped control due to waiver condition"
+		case r < spec.SkipRate:
+			result["status"] = controlSkipped
+			result["skip_message"] = "Skipped control due to only_if condition."
+		case r < spec.SkipRate+spec.FailureRate:
+			result["status"] = controlFailed
+			result["message"] = fmt.Sprintf("expected %s check %d to be compliant", controlID, i+1)
+		default:
+			result["status"] = controlPassed
+		}
+		results[i] = result
+	}
+
+	for i := 1; i <= spec.TagsPerControl; i++ {
+		tags[fmt.Sprintf("tag%d", i)] = fmt.Sprintf("value%d", rand.Intn(10))
+	}
+	for i := range refs {
+		refs[i] = map[string]interface{}{
+			"ref": fmt.Sprintf("Reference %d of %s", i+1, controlID),
+			"url": fmt.Sprintf("https://example.com/controls/%s#ref-%d", controlID, i+1),
+		}
+	}
+
+	control := map[string]interface{}{
+		"id":     controlID,
+		"title":  "Synthetic control " + controlID,
+		"desc":   "Synthetic control generated by chef-load",
+		"impact": syntheticImpact(spec.ImpactWeights),
+		"refs":   refs,
+		"tags":   tags,
+		"code":   syntheticCode(controlID, spec.CodeSize),
+		"source_location": map[string]interface{}{
+			"ref":  "controls/synthetic.rb",
+			"line": 1,
+		},
+		"results": results,
+	}
+
+	if waived {
+		control["waiver_data"] = map[string]interface{}{
+			"justification":         "Accepted risk, waived by chef-load",
+			"run":                   false,
+			"skipped_due_to_waiver": true,
+			"message":               "",
+			"expiration_date":       startTime.AddDate(0, 3, 0).Format("2006-01-02"),
+		}
+	}
+	if rand.Float64() < spec.AttestationRate {
+		control["attestation_data"] = map[string]interface{}{
+			"control_id":      controlID,
+			"explanation":     "Manually verified by chef-load",
+			"frequency":       "quarterly",
+			"status":          controlPassed,
+			"updated":         startTime.Format(time.RFC3339),
+			"updated_by":      "chef-load",
+			"expiration_date": startTime.AddDate(0, 3, 0).Format("2006-01-02"),
+		}
+	}
+	return control
+}
+
+// syntheticImpact chooses a severity according to the weights and returns an
+// impact within its range, every severity is equally likely without weights
+func syntheticImpact(weights map[string]float64) float64 {
+	severityWeights := make([]float64, len(impactSeverities))
+	for i, severity := range impactSeverities {
+		severityWeights[i] = 1.0
+		if len(weights) > 0 {
+			severityWeights[i] = weights[severity]
+		}
+	}
+	impactRange := impactRanges[impactSeverities[weightedChoice(severityWeights)]]
+	return impactRange[0] + rand.Float64()*(impactRange[1]-impactRange[0])
+}
+
+// syntheticCode returns the source of the control, padded with describe
+// blocks until it is about size bytes long
+func syntheticCode(controlID string, size int) string {
+	var code strings.Builder
+	code.WriteString(fmt.Sprintf("control %q do\n  impact 1.0\n  title \"Synthetic control %s\"\n", controlID, controlID))
+	for i := 1; code.Len() < size; i++ {
+		code.WriteString(fmt.Sprintf("  describe file('/etc/synthetic/%d') do\n    it { should exist }\n  end\n", i))
+	}
+	code.WriteString("end\n")
+	return code.String()
+}
@@ -0,0 +1,34 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSyntheticReport(t *testing.T) {
+	spec := Default().Matrix.Synthetic
+	spec.Profiles = 3
+	spec.ControlsPerProfile = 4
+	spec.ResultsPerControl = 2
+	spec.CodeSize = 1024
+	spec.WaiverRate = 1.0
+	spec.ImpactWeights = map[string]float64{"critical": 1.0}
+
+	report := generateSyntheticReport(spec, Platform{Name: "c6", Profiles: []string{"ssh-baseline-2.2.0"}})
+
+	profiles := report["profiles"].([]interface{})
+	assert.Len(t, profiles, 3)
+	assert.Equal(t, "ssh-baseline", profiles[0].(map[string]interface{})["name"])
+	assert.Equal(t, "2.2.0", profiles[0].(map[string]interface{})["version"])
+	assert.Equal(t, "synthetic-profile-2", profiles[1].(map[string]interface{})["name"])
+
+	controls := profiles[2].(map[string]interface{})["controls"].([]interface{})
+	assert.Len(t, controls, 4)
+	control := controls[0].(map[string]interface{})
+	assert.Len(t, control["results"], 2)
+	assert.True(t, len(control["code"].(string)) >= 1024)
+	assert.True(t, control["impact"].(float64) >= 0.9)
+	assert.NotNil(t, control["waiver_data"])
+	assert.Equal(t, controlSkipped, controlStatus(control))
+}
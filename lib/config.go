@@ -18,6 +18,7 @@
 package chef_load
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -31,12 +32,25 @@ type Samples struct {
 	Platforms []Platform `mapstructure:"platforms"`
 }
 
+// Frequency is a bucket of the fleet, the fraction of the nodes that are
+// scanned every interval minutes
+type Frequency struct {
+	Fraction float64 `mapstructure:"fraction"`
+	Interval int     `mapstructure:"interval"`
+}
+
 type Simulation struct {
-	Days          int    `mapstructure:"days"`
-	Nodes         int    `mapstructure:"nodes"`
-	MaxScans      int    `mapstructure:"max_scans"`
-	TotalMaxScans int    `mapstructure:"total_max_scans"`
-	SampleFormat  string `mapstructure:"format"`
+	Mode             string      `mapstructure:"mode"`
+	Days             int         `mapstructure:"days"`
+	Nodes            int         `mapstructure:"nodes"`
+	MaxScans         int         `mapstructure:"max_scans"`
+	TotalMaxScans    int         `mapstructure:"total_max_scans"`
+	SampleFormat     string      `mapstructure:"format"`
+	Frequencies      []Frequency `mapstructure:"frequencies"`
+	Jitter           float64     `mapstructure:"jitter"`
+	Concurrency      int         `mapstructure:"concurrency"`
+	NodeNameTemplate string      `mapstructure:"node_name_template"`
+	ScansPerSecond   float64     `mapstructure:"scans_per_second"`
 }
 
 type Statistics struct {
@@ -48,47 +62,212 @@ type Set struct {
 	ScanPerDay int `mapstructure:"scan_per_day"`
 }
 
+// Drift controls how the status of the controls of a node changes from one
+// compliance scan to the next, the rates are probabilities per control per scan
+type Drift struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	FixRate        float64 `mapstructure:"fix_rate"`
+	RegressionRate float64 `mapstructure:"regression_rate"`
+	FlappingRate   float64 `mapstructure:"flapping_rate"`
+	SkipRate       float64 `mapstructure:"skip_rate"`
+}
+
+// Synthetic is the spec of the InSpec reports generated when no sample reports are used
+type Synthetic struct {
+	Enabled            bool               `mapstructure:"enabled"`
+	Profiles           int                `mapstructure:"profiles"`
+	ControlsPerProfile int                `mapstructure:"controls_per_profile"`
+	ResultsPerControl  int                `mapstructure:"results_per_control"`
+	TagsPerControl     int                `mapstructure:"tags_per_control"`
+	RefsPerControl     int                `mapstructure:"refs_per_control"`
+	CodeSize           int                `mapstructure:"code_size"`
+	ImpactWeights      map[string]float64 `mapstructure:"impact_weights"`
+	FailureRate        float64            `mapstructure:"failure_rate"`
+	SkipRate           float64            `mapstructure:"skip_rate"`
+	WaiverRate         float64            `mapstructure:"waiver_rate"`
+	AttestationRate    float64            `mapstructure:"attestation_rate"`
+}
+
+// Scaling grows the compliance reports to stress the payload size limits of Automate
+type Scaling struct {
+	TargetBytes       int  `mapstructure:"target_bytes"`
+	TargetControls    int  `mapstructure:"target_controls"`
+	ResultsPerControl int  `mapstructure:"results_per_control"`
+	Gzip              bool `mapstructure:"gzip"`
+}
+
 type Matrix struct {
 	Samples    Samples    `mapstructure:"samples"`
 	Simulation Simulation `mapstructure:"simulation"`
 	Statistics Statistics `mapstructure:"statistics"`
+	Drift      Drift      `mapstructure:"drift"`
+	Synthetic  Synthetic  `mapstructure:"synthetic"`
+	Scaling    Scaling    `mapstructure:"scaling"`
+}
+
+// Actions holds the weights used to choose the type and task of the generated
+// actions, types and tasks without a weight default to 1.0
+type Actions struct {
+	IncludeProfiles bool                          `mapstructure:"include_profiles"`
+	Weights         map[string]float64            `mapstructure:"weights"`
+	TaskWeights     map[string]map[string]float64 `mapstructure:"task_weights"`
+}
+
+// Workload holds the API requests that the cookbooks make during each
+// chef-client run
+type Workload struct {
+	CallsPerRun  int                   `mapstructure:"calls_per_run"`
+	Searches     []SearchTemplate      `mapstructure:"searches"`
+	DataBagItems []DataBagItemTemplate `mapstructure:"data_bag_items"`
+}
+
+// SearchTemplate is a search of the workload, a partial search when it has
+// partial_keys
+type SearchTemplate struct {
+	Index       string   `mapstructure:"index"`
+	Query       string   `mapstructure:"query"`
+	Rows        int      `mapstructure:"rows"`
+	PartialKeys []string `mapstructure:"partial_keys"`
+	Weight      float64  `mapstructure:"weight"`
+}
+
+// DataBagItemTemplate is a data bag item read of the workload
+type DataBagItemTemplate struct {
+	Bag    string  `mapstructure:"bag"`
+	Item   string  `mapstructure:"item"`
+	Weight float64 `mapstructure:"weight"`
+}
+
+// APIRequest is an API request that the cookbooks make during each chef-client
+// run, its path and body are templates like the ones of the workload
+type APIRequest struct {
+	Method         string  `mapstructure:"method"`
+	Path           string  `mapstructure:"path"`
+	Body           string  `mapstructure:"body"`
+	Probability    float64 `mapstructure:"probability"`
+	ExpectedStatus int     `mapstructure:"expected_status"`
+}
+
+// ReleaseStorm is a release of new cookbook versions in the start mode
+type ReleaseStorm struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	After        int      `mapstructure:"after"`
+	Cookbooks    []string `mapstructure:"cookbooks"`
+	Count        int      `mapstructure:"count"`
+	ChangedFiles float64  `mapstructure:"changed_files"`
+}
+
+// Validate verifies that every weight refers to a known action type and task
+func (a *Actions) Validate() error {
+	knownType := func(name string) bool {
+		for _, typeName := range actionTypeString {
+			if typeName == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for name, weight := range a.Weights {
+		if !knownType(name) {
+			return fmt.Errorf("Unknown action type %q in actions.weights", name)
+		}
+		if weight < 0 {
+			return fmt.Errorf("The weight of action type %q must not be negative", name)
+		}
+	}
+	for name, taskWeights := range a.TaskWeights {
+		if !knownType(name) {
+			return fmt.Errorf("Unknown action type %q in actions.task_weights", name)
+		}
+		for taskName, weight := range taskWeights {
+			switch taskName {
+			case "create", "update", "delete":
+			default:
+				return fmt.Errorf("Unknown task %q in actions.task_weights.%s", taskName, name)
+			}
+			if weight < 0 {
+				return fmt.Errorf("The weight of task %q of action type %q must not be negative", taskName, name)
+			}
+		}
+	}
+
+	sum := func(weights []float64) (total float64) {
+		for _, weight := range weights {
+			total += weight
+		}
+		return total
+	}
+	mix := newActionMix(a)
+	if sum(mix.typeWeights) == 0 {
+		return errors.New("At least one action type must have a weight greater than zero")
+	}
+	for aType, taskWeights := range mix.taskWeights {
+		if mix.typeWeights[aType] > 0 && sum(taskWeights) == 0 {
+			return fmt.Errorf("At least one task of action type %q must have a weight greater than zero", actionTypeString[aType])
+		}
+	}
+	return nil
 }
 
 type Config struct {
 	RunChefClient                bool
-	LogFile                      string     `mapstructure:"log_file"`
-	ChefServerURL                string     `mapstructure:"chef_server_url"`
-	ClientName                   string     `mapstructure:"client_name"`
-	ClientKey                    string     `mapstructure:"client_key"`
-	DataCollectorURL             string     `mapstructure:"data_collector_url"`
-	DataCollectorToken           string     `mapstructure:"data_collector_token"`
-	OhaiJSONFile                 string     `mapstructure:"ohai_json_file"`
-	ConvergeStatusJSONFile       string     `mapstructure:"converge_status_json_file"`
-	ComplianceStatusJSONFile     string     `mapstructure:"compliance_status_json_file"`
-	ComplianceSampleReportsDir   string     `mapstructure:"compliance_sample_reports_dir"`
-	NumActions                   int        `mapstructure:"num_actions"`
-	NumNodes                     int        `mapstructure:"num_nodes"`
-	Interval                     int        `mapstructure:"interval"`
-	NodeNamePrefix               string     `mapstructure:"node_name_prefix"`
-	ChefEnvironment              string     `mapstructure:"chef_environment"`
-	RunList                      []string   `mapstructure:"run_list"`
-	RunLists                     [][]string `mapstructure:"run_lists"`
-	SleepDuration                int        `mapstructure:"sleep_duration"`
-	DownloadCookbooks            string     `mapstructure:"download_cookbooks"`
-	DownloadCookbooksScaleFactor float64    `mapstructure:"download_cookbooks_scale_factor"`
-	APIGetRequests               []string   `mapstructure:"api_get_requests"`
-	ChefVersion                  string     `mapstructure:"chef_version"`
-	ChefServerCreatesClientKey   bool       `mapstructure:"chef_server_creates_client_key"`
-	NodeSaveFrequency            float64    `mapstructure:"node_save_frequency"`
-	RandomData                   bool       `mapstructure:"random_data"`
-	LivenessAgent                bool       `mapstructure:"liveness_agent"`
-	EnableReporting              bool       `mapstructure:"enable_reporting"`
-	DaysBack                     int        `mapstructure:"days_back"`
-	Threads                      int        `mapstructure:"threads"`
-	SleepTimeOnFailure           int        `mapstructure:"sleep_time_on_failure"`
-	Matrix                       *Matrix    `mapstructure:"matrix"`
-	SkipClientCreation           bool       `mapstructure:"skip_client_creation"`
-	NodeReplacementRate          float64    `mapstructure:"node_replacement_rate"`
+	LogFile                      string              `mapstructure:"log_file"`
+	ChefServerURL                string              `mapstructure:"chef_server_url"`
+	ClientName                   string              `mapstructure:"client_name"`
+	ClientKey                    string              `mapstructure:"client_key"`
+	DataCollectorURL             string              `mapstructure:"data_collector_url"`
+	DataCollectorToken           string              `mapstructure:"data_collector_token"`
+	OhaiJSONFile                 string              `mapstructure:"ohai_json_file"`
+	ConvergeStatusJSONFile       string              `mapstructure:"converge_status_json_file"`
+	ComplianceStatusJSONFile     string              `mapstructure:"compliance_status_json_file"`
+	ComplianceSampleReportsDir   string              `mapstructure:"compliance_sample_reports_dir"`
+	NumActions                   int                 `mapstructure:"num_actions"`
+	ActionTimeDistribution       string              `mapstructure:"action_time_distribution"`
+	SimulatedActions             bool                `mapstructure:"simulated_actions"`
+	ActionAdminUsers             []string            `mapstructure:"action_admin_users"`
+	NumNodes                     int                 `mapstructure:"num_nodes"`
+	Interval                     int                 `mapstructure:"interval"`
+	NodeNamePrefix               string              `mapstructure:"node_name_prefix"`
+	ChefEnvironment              string              `mapstructure:"chef_environment"`
+	RunList                      []string            `mapstructure:"run_list"`
+	RunLists                     [][]string          `mapstructure:"run_lists"`
+	SleepDuration                int                 `mapstructure:"sleep_duration"`
+	ConvergeDuration             *ConvergeDuration   `mapstructure:"converge_duration"`
+	DownloadCookbooks            string              `mapstructure:"download_cookbooks"`
+	DownloadCookbooksScaleFactor float64             `mapstructure:"download_cookbooks_scale_factor"`
+	DownloadCookbooksConcurrency int                 `mapstructure:"download_cookbooks_concurrency"`
+	DownloadCookbooksStream      bool                `mapstructure:"download_cookbooks_stream"`
+	CookbookCacheEviction        string              `mapstructure:"cookbook_cache_eviction"`
+	CookbookCacheWipeRate        float64             `mapstructure:"cookbook_cache_wipe_rate"`
+	APIGetRequests               []string            `mapstructure:"api_get_requests"`
+	APIRequests                  []APIRequest        `mapstructure:"api_requests"`
+	RunStartRequests             []string            `mapstructure:"run_start_requests"`
+	RoleCacheTTL                 int                 `mapstructure:"role_cache_ttl"`
+	ChefVersion                  string              `mapstructure:"chef_version"`
+	ChefVersions                 []ChefVersionWeight `mapstructure:"chef_versions"`
+	PolicyName                   string              `mapstructure:"policy_name"`
+	PolicyGroup                  string              `mapstructure:"policy_group"`
+	ChefServerCreatesClientKey   bool                `mapstructure:"chef_server_creates_client_key"`
+	NodeSaveFrequency            float64             `mapstructure:"node_save_frequency"`
+	RandomData                   bool                `mapstructure:"random_data"`
+	LivenessAgent                bool                `mapstructure:"liveness_agent"`
+	LivenessInterval             int                 `mapstructure:"liveness_interval"`
+	LivenessJitter               float64             `mapstructure:"liveness_jitter"`
+	LivenessOnlyNodes            int                 `mapstructure:"liveness_only_nodes"`
+	EnableReporting              bool                `mapstructure:"enable_reporting"`
+	ReportingTotalResources      int                 `mapstructure:"reporting_total_resources"`
+	ReportingUpdatedResources    int                 `mapstructure:"reporting_updated_resources"`
+	DaysBack                     int                 `mapstructure:"days_back"`
+	Threads                      int                 `mapstructure:"threads"`
+	SleepTimeOnFailure           int                 `mapstructure:"sleep_time_on_failure"`
+	Actions                      *Actions            `mapstructure:"actions"`
+	Matrix                       *Matrix             `mapstructure:"matrix"`
+	ReleaseStorm                 *ReleaseStorm       `mapstructure:"release_storm"`
+	Workload                     *Workload           `mapstructure:"workload"`
+	SkipClientCreation           bool                `mapstructure:"skip_client_creation"`
+	NodeReplacementRate          float64             `mapstructure:"node_replacement_rate"`
+	UnifiedNodes                 bool                `mapstructure:"unified_nodes"`
 }
 
 func Default() Config {
@@ -111,25 +290,70 @@ func Default() Config {
 		SleepDuration:                0,
 		DownloadCookbooks:            "never",
 		DownloadCookbooksScaleFactor: 1.0,
+		DownloadCookbooksConcurrency: 1,
+		DownloadCookbooksStream:      false,
+		CookbookCacheEviction:        staleCacheEviction,
+		CookbookCacheWipeRate:        0.0,
 		ChefVersion:                  "13.2.20",
+		ChefVersions:                 []ChefVersionWeight{},
+		PolicyName:                   "",
+		PolicyGroup:                  "",
+		APIRequests:                  []APIRequest{},
+		RunStartRequests:             []string{nodeRunStartRequest, rolesRunStartRequest, environmentRunStartRequest},
+		RoleCacheTTL:                 0,
 		NodeSaveFrequency:            1.0,
 		ChefServerCreatesClientKey:   false,
 		EnableReporting:              false,
+		ReportingTotalResources:      0,
+		ReportingUpdatedResources:    0,
 		RandomData:                   false,
 		LivenessAgent:                false,
+		LivenessInterval:             30,
+		LivenessJitter:               0.0,
+		LivenessOnlyNodes:            0,
 		NumActions:                   30,
+		ActionTimeDistribution:       "uniform",
+		SimulatedActions:             false,
+		UnifiedNodes:                 false,
+		ActionAdminUsers:             []string{"admin"},
 		DaysBack:                     0,
 		Threads:                      3000,
 		SleepTimeOnFailure:           5,
 		SkipClientCreation:           false,
 		NodeReplacementRate:          0.0,
+		Actions: &Actions{
+			IncludeProfiles: false,
+			Weights:         map[string]float64{},
+			TaskWeights:     map[string]map[string]float64{},
+		},
+		ReleaseStorm: &ReleaseStorm{
+			Enabled:      false,
+			After:        10,
+			Cookbooks:    []string{},
+			Count:        1,
+			ChangedFiles: 0.2,
+		},
+		ConvergeDuration: &ConvergeDuration{
+			Groups: []ConvergeDurationGroup{},
+		},
+		Workload: &Workload{
+			CallsPerRun:  0,
+			Searches:     []SearchTemplate{},
+			DataBagItems: []DataBagItemTemplate{},
+		},
 		Matrix: &Matrix{
 			Simulation: Simulation{
-				Days:          1,
-				Nodes:         0,
-				MaxScans:      2,
-				TotalMaxScans: 2,
-				SampleFormat:  "full",
+				Mode:             "simulation",
+				Days:             1,
+				Nodes:            0,
+				MaxScans:         2,
+				TotalMaxScans:    2,
+				SampleFormat:     "full",
+				Frequencies:      []Frequency{},
+				Jitter:           0.0,
+				Concurrency:      0,
+				NodeNameTemplate: defaultNodeNameTemplate,
+				ScansPerSecond:   0,
 			},
 			Samples: Samples{
 				Platforms: []Platform{
@@ -141,13 +365,40 @@ func Default() Config {
 					{Name: "d8-2", Profiles: []string{"mylinux-failure-major-5.4.4"}},
 					{Name: "f22", Profiles: []string{"linux-baseline-2.2.0", "ssh-baseline-2.2.0",
 						"apache-baseline-2.0.2", "mysql-baseline-2.1.0"}},
-					{Name: "u12", Profiles: []string{"cis-ubuntu12_04lts-level1-1.1.0-2"}},
+					{Name: "u12", Profiles: []string{"cis-ubuntu12.04lts-level1-1.1.0-2"}},
 					{Name: "u14", Profiles: []string{"mylinux-success-1.8.9"}},
 					//{Name: "u18", Profiles: []string{"linux-baseline-2.2.0", "ssh-baseline-2.2.0"}},
 					//{Name: "osx17-7", Profiles: []string{"linux-baseline-2.2.0", "ssh-baseline-2.2.0"}},
 					//{Name: "csv_breaker", Profiles: []string{"linux-baseline-2.2.0", "ssh-baseline-2.2.0"}},
 				},
 			},
+			Drift: Drift{
+				Enabled:        false,
+				FixRate:        0.05,
+				RegressionRate: 0.01,
+				FlappingRate:   0.02,
+				SkipRate:       0.0,
+			},
+			Synthetic: Synthetic{
+				Enabled:            false,
+				Profiles:           0,
+				ControlsPerProfile: 50,
+				ResultsPerControl:  3,
+				TagsPerControl:     2,
+				RefsPerControl:     1,
+				CodeSize:           512,
+				ImpactWeights:      map[string]float64{},
+				FailureRate:        0.2,
+				SkipRate:           0.05,
+				WaiverRate:         0.0,
+				AttestationRate:    0.0,
+			},
+			Scaling: Scaling{
+				TargetBytes:       0,
+				TargetControls:    0,
+				ResultsPerControl: 0,
+				Gzip:              false,
+			},
 			Statistics: Statistics{
 				Sets: []Set{
 					{Nodes: 1, ScanPerDay: 24},
@@ -208,6 +459,14 @@ func PrintSampleConfig() {
 # See the chef-load README for instructions for obtaining the samples.
 # compliance_sample_reports_dir = "/path/to/sample-data/inspec-reports"
 
+# When true, the converge nodes also report compliance with the same name, UUID, environment,
+# roles, recipes, platform and IP address as their chef-client runs, all derived from the node name.
+# Their platform is one of the matrix.samples.platforms and their run list one of run_lists.
+# The start command sends a compliance report of the platform after every chef-client run and
+# the "simulation" mode of the generate command scans the num_nodes converge nodes instead
+# of generating matrix.simulation.nodes nodes.
+# unified_nodes = false
+
 # chef-load will evenly distribute the number of nodes across the desired interval (minutes)
 # Examples:
 #   30 nodes / 30 minute interval =  1 chef-client run per minute
@@ -219,6 +478,22 @@ func PrintSampleConfig() {
 # Ignored if data_collector_url is not set.
 # num_actions = 30
 
+# action_time_distribution controls how the recorded time of the generated actions is spread
+# over the last days_back days (or the last week when days_back is not set).
+# Options are: "uniform", "business_hours" (mostly weekdays between 9am and 5pm),
+# "bursty" (mostly clustered around a couple of random points in time per day)
+# action_time_distribution = "uniform"
+
+# When simulated_actions is true the actions reference the entities chef-load is simulating
+# instead of random names: the "<node_name_prefix>-N" nodes and their clients, the cookbooks and roles
+# of run_list/run_lists, chef_environment and the organization of chef_server_url.
+# Nodes replaced because of node_replacement_rate also send the matching node and client
+# create/delete actions.
+# simulated_actions = false
+
+# The users that make the user, permission and other administrative changes when simulated_actions is true
+# action_admin_users = [ "admin" ]
+
 # This prefix will go at the beginning of each node name.
 # This enables running multiple instances of chef-load without affecting each others' nodes
 # For example, a value of "chef-load" will result in nodes named "chef-load-1", "chef-load-2", ...
@@ -246,12 +521,42 @@ func PrintSampleConfig() {
 # sleep_duration is measured in seconds
 # sleep_duration = 0
 
+# The converge_duration section samples the converge time of every chef-client run from a distribution
+# instead of using sleep_duration. The converge time is the time between the start_time and the end_time
+# reported to the data collector, so longer converge times also mean more chef-client runs in flight.
+# The distributions, in seconds, are:
+#   * constant: seconds
+#   * uniform: between min and max
+#   * normal: mean and stddev
+#   * lognormal: mean and stddev of the long-tailed converge time (not of its logarithm)
+#   * empirical: a histogram file whose lines hold the seconds of a converge time and how many
+#     runs took them, like "45,120"
+# Instead of a single distribution, [[converge_duration.groups]] can give a different distribution to a
+# share of the nodes, picked by weight. A node stays in the same group on every run.
+#
+# [converge_duration]
+# distribution = "lognormal"
+# mean = 90.0
+# stddev = 60.0
+#
+# [[converge_duration.groups]]
+# weight = 0.9
+# distribution = "uniform"
+# min = 30.0
+# max = 90.0
+# [[converge_duration.groups]]
+# weight = 0.1
+# distribution = "empirical"
+# file = "/path/to/converge-times.csv"
+
 # days_back is an optional setting that allows the load of historical data. When provided, the tool
 # will use this value to load the data from today to the provided day back.
 # days_back = 30
 
 # download_cookbooks controls which chef-client run downloads cookbook files.
 # Options are: "never", "first" (first chef-client run only), "always""
+# or "cache" (every run downloads the files that aren't in the node's file cache yet,
+# so a new cookbook version only downloads its changed files)
 #
 # Downloading cookbooks can significantly increase the number of API requests that chef-load
 # makes depending on the run_list. If you aren't concerned with simulating the download of cookbook files
@@ -267,6 +572,20 @@ func PrintSampleConfig() {
 #
 # download_cookbooks_scale_factor = 1.0
 
+# The number of cookbook files that every chef-client run downloads in parallel.
+# When download_cookbooks_stream is true the files are discarded as they arrive instead of being
+# read into memory, so big cookbooks don't increase the memory use of chef-load.
+# The profile of API requests also reports the bytes downloaded from every endpoint.
+# download_cookbooks_concurrency = 1
+# download_cookbooks_stream = false
+
+# When download_cookbooks == "cache", cookbook_cache_eviction controls which files the nodes keep:
+# "stale" removes the files of the cookbooks that the run didn't use, like chef-client does,
+# "never" keeps every file. cookbook_cache_wipe_rate is the probability (0.0 - 1.0) that a node
+# starts a run with an empty cache, like a rebuilt node.
+# cookbook_cache_eviction = "stale"
+# cookbook_cache_wipe_rate = 0.0
+
 
 # api_get_requests is an optional list of API GET requests that are made during the chef-client run.
 # This is used to simulate the API requests that the cookbooks would make.
@@ -282,9 +601,59 @@ func PrintSampleConfig() {
 #
 # api_get_requests = [ ]
 
+# api_requests is an optional list of API requests of any method that are made during the chef-client run,
+# after api_get_requests. This is used to simulate cookbooks that write data bag items, tag nodes or
+# update attributes. The path and the JSON body are templates that can use the variables of the
+# [workload] section: {node_name}, {environment}, {role} and {random:N}. Each request is made with
+# probability (0.0 - 1.0) on every run. A response whose status isn't expected_status (any 2xx status
+# when it isn't set) fails the chef-client run, like the exception the cookbook would raise.
+#
+# [[api_requests]]
+# method = "PUT"
+# path = "data/heartbeats/{node_name}"
+# body = '{"id": "{node_name}", "environment": "{environment}"}'
+# probability = 0.5
+# expected_status = 200
+
+# run_start_requests sets which API requests start a chef-client run and in which order.
+# "node" gets (and creates) the node, "roles" gets every role of the run_list while expanding it and
+# "environment" gets the node's environment. Remove or reorder the values to match the requests of a
+# specific chef-client version. A role that can't be fetched fails the chef-client run.
+# "roles" is required when the run lists have roles or policy_name is set, since it also loads the policy.
+# The default is [ "node", "roles", "environment" ]
+# run_start_requests = [ "node", "roles", "environment" ]
+
+# role_cache_ttl sets how many minutes each node keeps the roles it fetched before fetching them again.
+# The default is 0, which gets every role on every chef-client run.
+# role_cache_ttl = 0
+
 # chef_version sets the value of the X-Chef-Version HTTP header in API requests sent to the Chef Server.
 # This value represents the version of the Chef Client making the API requests. The default is "13.2.20"
 # chef_version = "13.2.20"
+#
+# The major version of chef_version selects a built-in profile of how that chef-client talks to the
+# Chef Server and the Data Collector. The profiles of 12, 14, 16 and 18 differ in:
+#   * the X-Ops-Server-Api-Version header: 0 for 12, 1 for the others
+#   * chef_server_creates_client_key: 12 ignores it, API version 0 has no create_key
+#   * the node save: 16 and 18 save only a typical automatic_attribute_allowlist, the others save every attribute
+#   * the Data Collector message_version: "1.0.0" for 12, "1.1.0" for the others
+#   * policyfiles: 12 converges the run_list even when policy_name is set
+# Any other version uses API version 1, saves every attribute and sends message_version "1.1.0".
+
+# chef_versions simulates a mixed-version fleet. Each node runs one of the versions, picked by weight,
+# and keeps it on every run. When it is empty every node runs chef_version.
+# [[chef_versions]]
+# version = "12.22.5"
+# weight = 0.2
+# [[chef_versions]]
+# version = "18.2.7"
+# weight = 0.8
+
+# policy_name and policy_group make the nodes converge a policy instead of their run_list.
+# Policy nodes get the policy revision from the policy group instead of their roles and environment,
+# and download the cookbook artifacts that the policy locks.
+# policy_name = "base"
+# policy_group = "production"
 
 # Ever since Chef Client 12.x was released the default behavior has been for the Chef Client to create its
 # own client key locally and then upload the public side to the Chef Server when it creates the client object.
@@ -310,16 +679,91 @@ func PrintSampleConfig() {
 # Send data to the Chef server's Reporting service
 # enable_reporting = false
 
+# The resources of the runs sent to Reporting, independent of the resources sent to the data collector.
+# reporting_total_resources is the size of the resource collection of every run (total_res_count) and
+# reporting_updated_resources the number of them that were updated, with their before and after state.
+# Failed runs also include the exception of their last updated resource.
+# reporting_total_resources = 0
+# reporting_updated_resources = 0
+
 # Generate Random Data
 # random_data = true
 
 # Generate Liveness Agent Data
 # liveness_agent = true
 
+# Interval between a node's liveness agent pings, in minutes
+# liveness_interval = 30
+
+# How much (0.0 - 1.0) each liveness ping delay is allowed to vary from liveness_interval.
+# For example, 0.1 with a 30 minute interval spreads the pings between 27 and 33 minutes.
+# liveness_jitter = 0.0
+
+# Number of additional nodes that only run the liveness agent and never converge.
+# Ignored unless liveness_agent is true.
+# These nodes are named "<node_name_prefix>-liveness-1", "<node_name_prefix>-liveness-2", ...
+# When days_back is set, the generate command will also backfill their liveness history.
+# liveness_only_nodes = 0
+
+# Weights used to choose the type and task of every generated action. The bigger the weight
+# the more often the action type or task is chosen. Action types and tasks that are not listed
+# have a weight of 1.0, so by default every action type and task is equally likely.
+# Action types: node, cookbook, bag, environment, role, policy, group, organization,
+#               permission, user, item, version, client, profile
+# Tasks: create, update, delete
+[actions]
+  # Include the upload (create), update and delete of the compliance profiles listed
+  # in matrix.samples.platforms
+  # include_profiles = false
+
+  # In a real Chef Server most of the actions are node updates and cookbook uploads
+  # [actions.weights]
+  # node = 60.0
+  # version = 15.0
+  # cookbook = 5.0
+  # organization = 0.1
+
+  # [actions.task_weights.node]
+  # create = 1.0
+  # update = 50.0
+  # delete = 1.0
+
+# Simulate the release of new cookbook versions in the start mode. "after" minutes after chef-load
+# starts, the cookbooks (or "count" random cookbooks of the recipes of the run lists when none
+# are listed, so list them when the run lists only have roles) get a new version that changes a fraction (changed_files) of their files, and their version
+# create actions are sent to data_collector_url. The next chef-client run of every node downloads
+# the changed files, so it requires download_cookbooks = "cache". The requests of the following
+# interval, when the whole fleet picks up the release, are profiled as their own phase.
+[release_storm]
+enabled = false
+after = 10
+cookbooks = []
+count = 1
+changed_files = 0.2
+
+# Simulate the searches and data bag reads of the cookbooks. Every chef-client run makes calls_per_run
+# requests, each one picked by weight among the searches and data bag items. The templates can use
+# the variables {node_name}, {environment}, {role} (a random role of the node's run_list) and
+# {random:N} (a random index between 0 and N-1). Searches with partial_keys are partial searches
+# that POST the attribute paths they return, like search(:node, query, filter_result: {...}).
+[workload]
+calls_per_run = 0
+
+  # [[workload.searches]]
+  # index = "node"
+  # query = "role:{role} AND chef_environment:{environment}"
+  # rows = 1000
+  # partial_keys = ["name", "ipaddress", "kernel.machine"]
+  # weight = 3.0
+
+  # [[workload.data_bag_items]]
+  # bag = "users"
+  # item = "user-{random:500}"
+  # weight = 1.0
+
 # Matrix settings for Compliance Generation.  This is to ensure a diversity of nodes/scan/profiles
 # for compliance data. This only applied when running in "this day back" or "generate" mode.
-# In the future, it would be great if we could harmonize this with the converge nodes so that
-# we will have consistancy between that which is ingested between the two
+# Set unified_nodes to scan the converge nodes instead of generating compliance nodes.
 [matrix]
    #The samples listed in this section (matrix.samples), specify the profiles that each individual node will have included in their respective scans.
   [matrix.samples]
@@ -349,7 +793,7 @@ func PrintSampleConfig() {
     name = "d7"
     target = "ssh://root@0.0.0.0:11029"
     profiles = [
-      "apache-baseline-1.0.2"
+      "apache-baseline-2.0.2"
     ]
 
     [[matrix.samples.platforms]]
@@ -380,7 +824,7 @@ func PrintSampleConfig() {
     name = "u12"
     target = "ssh://root@0.0.0.0:11022"
     profiles = [
-      "cis-ubuntu12_04lts-level1-1.1.0-2"
+      "cis-ubuntu12.04lts-level1-1.1.0-2"
     ]
 
     [[matrix.samples.platforms]]
@@ -390,21 +834,114 @@ func PrintSampleConfig() {
       "mylinux-success-1.8.9"
     ]
 
-    [[matrix.samples.platforms]]
-    name = "u18"
-    target = "ssh://root@0.0.0.0:11033"
-    profiles = [
-      "linux-baseline-2.2.0",
-      "ssh-baseline-2.2.0"
-    ]
-
+    # u18 only has a "min" sample report
+    # [[matrix.samples.platforms]]
+    # name = "u18"
+    # target = "ssh://root@0.0.0.0:11033"
+    # profiles = [
+    #   "linux-baseline-2.2.0",
+    #   "ssh-baseline-2.2.0"
+    # ]
+
+  # The mode selects which nodes are scanned and how often:
+  #   "simulation" - the matrix.simulation nodes: 10% of them scanned max_scans times a day,
+  #                  30% daily, 40% weekly and 20% monthly
+  #   "statistics" - the nodes of every matrix.statistics.sets entry, scanned scan_per_day times a day
+  #   "benchmark"  - every matrix.statistics.sets entry in turn, printing the results of each set
   [matrix.simulation]
+  mode = "simulation"
   days = 10
   nodes = 50
   max_scans = 2
   total_max_scans = 1000000
   sample_format = "full"
 
+  # The name of the generated compliance nodes, the placeholders are replaced with:
+  #   {prefix}   - node_name_prefix
+  #   {index}    - the number of the node, it keeps the names unique and is required
+  #   {platform} - the platform of the node
+  #   {words}    - a color-street-color combination derived from the number of the node
+  # The UUID of a node is derived from its name, so repeated runs address the same nodes.
+  node_name_template = "{prefix}-{words}-{index}"
+
+  # The number of workers sending the reports, when it is 0 the threads setting is used.
+  # scans_per_second limits the reports sent by all the workers together, 0 means no limit.
+  # When a report is rejected every worker sleeps for sleep_time_on_failure seconds.
+  concurrency = 0
+  scans_per_second = 0.0
+
+  # How much (0.0 - 1.0) the end time of each scan is allowed to vary from its interval
+  jitter = 0.0
+
+  # The scan frequency of the "simulation" mode nodes, as buckets of the fleet with the fraction
+  # of the nodes and the interval in minutes between their scans. The fractions are relative to their sum.
+  # When no bucket is set, 10% of the nodes are scanned max_scans times a day, 30% daily,
+  # 40% weekly and 20% monthly, like the following buckets with max_scans = 2:
+  #
+  # [[matrix.simulation.frequencies]]
+  # fraction = 0.1
+  # interval = 720
+  #
+  # [[matrix.simulation.frequencies]]
+  # fraction = 0.3
+  # interval = 1440
+  #
+  # [[matrix.simulation.frequencies]]
+  # fraction = 0.4
+  # interval = 10080
+  #
+  # [[matrix.simulation.frequencies]]
+  # fraction = 0.2
+  # interval = 43200
+
+  # By default every scan of a node sends the same control results as its sample report.
+  # When drift is enabled the results of each scan are derived from the previous scan of the node:
+  # failed controls get fixed (fix_rate), passed controls regress (regression_rate),
+  # a fraction of the controls flap between passed and failed (flapping_rate) and
+  # controls are skipped for a single scan (skip_rate). The rates are probabilities per control per scan.
+  [matrix.drift]
+  enabled = false
+  fix_rate = 0.05
+  regression_rate = 0.01
+  flapping_rate = 0.02
+  skip_rate = 0.0
+
+  # Generate the InSpec reports instead of loading them from compliance_sample_reports_dir.
+  # Each report has the profiles of the node's platform (matrix.samples.platforms), plus made up
+  # profiles until there are "profiles" of them (0 means only the platform's profiles).
+  # impact_weights sets how likely each severity is: critical, high, medium, low and none.
+  # failure_rate and skip_rate are the probability of each result failing or being skipped,
+  # waiver_rate and attestation_rate the probability of each control being waived or attested.
+  # code_size is the approximate size in bytes of the code of each control.
+  [matrix.synthetic]
+  enabled = false
+  profiles = 0
+  controls_per_profile = 50
+  results_per_control = 3
+  tags_per_control = 2
+  refs_per_control = 1
+  code_size = 512
+  failure_rate = 0.2
+  skip_rate = 0.05
+  waiver_rate = 0.0
+  attestation_rate = 0.0
+    # [matrix.synthetic.impact_weights]
+    # critical = 1.0
+    # high = 2.0
+    # medium = 4.0
+    # low = 2.0
+    # none = 1.0
+
+  # Grow the "full" reports to stress the payload size limits of Automate. The results of every control
+  # are repeated up to results_per_control and the controls are repeated until the report has
+  # target_controls controls and is target_bytes long. 0 leaves the reports as they are.
+  # When gzip is true the reports sent to data_collector_url are compressed with "Content-Encoding: gzip".
+  [matrix.scaling]
+  target_bytes = 0
+  target_controls = 0
+  results_per_control = 0
+  gzip = false
+
   [matrix.statistics]
 
     [[matrix.statistics.sets]]
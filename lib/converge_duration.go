@@ -0,0 +1,222 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	constantDistribution  = "constant"
+	uniformDistribution   = "uniform"
+	normalDistribution    = "normal"
+	lognormalDistribution = "lognormal"
+	empiricalDistribution = "empirical"
+)
+
+// DurationDistribution is the distribution of the converge time in seconds
+type DurationDistribution struct {
+	Distribution string  `mapstructure:"distribution"`
+	Seconds      float64 `mapstructure:"seconds"`
+	Min          float64 `mapstructure:"min"`
+	Max          float64 `mapstructure:"max"`
+	Mean         float64 `mapstructure:"mean"`
+	StdDev       float64 `mapstructure:"stddev"`
+	File         string  `mapstructure:"file"`
+}
+
+// ConvergeDurationGroup is the converge time of a share of the nodes
+type ConvergeDurationGroup struct {
+	DurationDistribution `mapstructure:",squash"`
+	Weight               float64 `mapstructure:"weight"`
+}
+
+// ConvergeDuration holds the converge time of every node, or of the nodes of
+// each group when there are groups
+type ConvergeDuration struct {
+	DurationDistribution `mapstructure:",squash"`
+	Groups               []ConvergeDurationGroup `mapstructure:"groups"`
+}
+
+// histogramBucket is a line of an empirical histogram file
+type histogramBucket struct {
+	seconds float64
+	count   float64
+}
+
+// readHistogram reads a histogram file, every line holds the seconds of a
+// converge time and how many runs took them, separated by a comma
+func readHistogram(file string) ([]histogramBucket, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		buckets []histogramBucket
+		total   float64
+	)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d must be seconds,count", file, line)
+		}
+		seconds, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("%s:%d seconds must be a number not less than zero", file, line)
+		}
+		count, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("%s:%d count must be a number not less than zero", file, line)
+		}
+		buckets = append(buckets, histogramBucket{seconds: seconds, count: count})
+		total += count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%s has no runs", file)
+	}
+	return buckets, nil
+}
+
+// histograms holds the histogram files that were read, every run samples them
+type histograms struct {
+	mu    sync.Mutex
+	files map[string][]histogramBucket
+}
+
+var empiricalHistograms = &histograms{files: map[string][]histogramBucket{}}
+
+func (h *histograms) get(file string) ([]histogramBucket, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if buckets, ok := h.files[file]; ok {
+		return buckets, nil
+	}
+	buckets, err := readHistogram(file)
+	if err != nil {
+		return nil, err
+	}
+	h.files[file] = buckets
+	return buckets, nil
+}
+
+// sample returns a converge time in seconds, never less than zero
+func (d DurationDistribution) sample() float64 {
+	var seconds float64
+	switch d.Distribution {
+	case uniformDistribution:
+		seconds = d.Min + rand.Float64()*(d.Max-d.Min)
+	case normalDistribution:
+		seconds = d.Mean + rand.NormFloat64()*d.StdDev
+	case lognormalDistribution:
+		// mean and stddev are the ones of the converge time, not of its logarithm
+		if d.Mean <= 0 {
+			return 0
+		}
+		variance := d.StdDev * d.StdDev
+		sigma := math.Sqrt(math.Log(1 + variance/(d.Mean*d.Mean)))
+		mu := math.Log(d.Mean) - sigma*sigma/2
+		seconds = math.Exp(mu + rand.NormFloat64()*sigma)
+	case empiricalDistribution:
+		buckets, err := empiricalHistograms.get(d.File)
+		if err != nil {
+			log.WithField("error", err).Error("Could not read the converge time histogram")
+			return 0
+		}
+		counts := make([]float64, len(buckets))
+		for i, bucket := range buckets {
+			counts[i] = bucket.count
+		}
+		seconds = buckets[weightedChoice(counts)].seconds
+	default:
+		seconds = d.Seconds
+	}
+	return math.Max(seconds, 0)
+}
+
+// validate returns the problems of the distribution, name is the config
+// section that holds it
+func (d DurationDistribution) validate(name string) []error {
+	var problems []error
+	switch d.Distribution {
+	case constantDistribution:
+		if d.Seconds < 0 {
+			problems = append(problems, fmt.Errorf("%s.seconds must not be negative", name))
+		}
+	case uniformDistribution:
+		if d.Min < 0 || d.Max < d.Min {
+			problems = append(problems, fmt.Errorf("%s.min must not be negative nor greater than %s.max", name, name))
+		}
+	case normalDistribution, lognormalDistribution:
+		if d.Mean <= 0 || d.StdDev < 0 {
+			problems = append(problems, fmt.Errorf("%s.mean must be greater than zero and %s.stddev must not be negative", name, name))
+		}
+	case empiricalDistribution:
+		if _, err := readHistogram(d.File); err != nil {
+			problems = append(problems, fmt.Errorf("%s.file: %s", name, err))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("%s.distribution %q must be one of constant, uniform, normal, lognormal or empirical", name, d.Distribution))
+	}
+	return problems
+}
+
+// enabled returns whether a distribution is configured, otherwise the runs
+// use sleep_duration
+func (cd *ConvergeDuration) enabled() bool {
+	return cd != nil && (cd.Distribution != "" || len(cd.Groups) > 0)
+}
+
+// nodeConvergeDuration returns a converge time of the node, sampled from the
+// distribution of its group
+func nodeConvergeDuration(config *Config, nodeName string) time.Duration {
+	cd := config.ConvergeDuration
+	distribution := cd.DurationDistribution
+	if len(cd.Groups) > 0 {
+		weights := make([]float64, len(cd.Groups))
+		for i, group := range cd.Groups {
+			weights[i] = group.Weight
+		}
+		// A node stays in the same group on every run
+		nodeUUID := uuid.NewMD5(uuid.NameSpaceDNS, []byte(nodeName))
+		fraction := float64(binary.BigEndian.Uint32(nodeUUID[8:12])) / float64(1<<32)
+		distribution = cd.Groups[weightedIndex(weights, fraction)].DurationDistribution
+	}
+	return time.Duration(distribution.sample() * float64(time.Second))
+}
@@ -0,0 +1,57 @@
+package chef_load
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationDistributionSample(t *testing.T) {
+	assert.Equal(t, 30.0, DurationDistribution{Distribution: "constant", Seconds: 30}.sample())
+
+	uniform := DurationDistribution{Distribution: "uniform", Min: 10, Max: 20}
+	lognormal := DurationDistribution{Distribution: "lognormal", Mean: 60, StdDev: 120}
+	normal := DurationDistribution{Distribution: "normal", Mean: 1, StdDev: 10}
+	var lognormalTotal float64
+	for i := 0; i < 10000; i++ {
+		seconds := uniform.sample()
+		assert.True(t, seconds >= 10 && seconds < 20)
+		// Converge times are never negative
+		assert.True(t, normal.sample() >= 0)
+		lognormalTotal += lognormal.sample()
+	}
+	assert.InDelta(t, 60, lognormalTotal/10000, 10)
+}
+
+func TestDurationDistributionEmpirical(t *testing.T) {
+	dir, err := ioutil.TempDir("", "converge-duration")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "histogram.csv")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("# seconds,count\n45,3\n600,0\n"), 0644))
+	empirical := DurationDistribution{Distribution: "empirical", File: file}
+	assert.Empty(t, empirical.validate("converge_duration"))
+	assert.Equal(t, 45.0, empirical.sample())
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte("45\n"), 0644))
+	assert.Len(t, DurationDistribution{Distribution: "empirical", File: file}.validate("converge_duration"), 1)
+}
+
+func TestNodeConvergeDurationGroups(t *testing.T) {
+	config := Default()
+	assert.False(t, config.ConvergeDuration.enabled())
+
+	config.ConvergeDuration.Groups = []ConvergeDurationGroup{
+		{Weight: 1, DurationDistribution: DurationDistribution{Distribution: "constant", Seconds: 10}},
+		{Weight: 1, DurationDistribution: DurationDistribution{Distribution: "constant", Seconds: 20}},
+	}
+	assert.True(t, config.ConvergeDuration.enabled())
+	// A node keeps its group on every run
+	assert.Equal(t, nodeConvergeDuration(&config, "chef-load-1"), nodeConvergeDuration(&config, "chef-load-1"))
+	assert.Contains(t, []time.Duration{10 * time.Second, 20 * time.Second}, nodeConvergeDuration(&config, "chef-load-1"))
+}
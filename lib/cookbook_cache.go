@@ -0,0 +1,109 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+// This file keeps the virtual file cache of every simulated node so that the
+// "cache" download mode only downloads the cookbook files that a node doesn't
+// hold yet, like chef-client does with its file cache
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/go-chef/chef"
+)
+
+// Supported values of cookbook_cache_eviction
+const (
+	// Remove the files of the cookbooks that the run didn't use, like chef-client does
+	staleCacheEviction = "stale"
+	// Keep every downloaded file
+	neverCacheEviction = "never"
+)
+
+// nodeFileCache maps the path of the files that the node holds, prefixed with
+// the name of their cookbook, to their checksum
+type nodeFileCache map[string]string
+
+// fileCaches holds the file cache of every node
+type fileCaches struct {
+	mu    sync.Mutex
+	nodes map[string]nodeFileCache
+}
+
+var cookbookFileCaches = &fileCaches{nodes: map[string]nodeFileCache{}}
+
+// node returns the file cache of the node, the runs of a node never overlap so
+// the cache itself isn't locked
+func (c *fileCaches) node(nodeName string) nodeFileCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cache, ok := c.nodes[nodeName]
+	if !ok {
+		cache = nodeFileCache{}
+		c.nodes[nodeName] = cache
+	}
+	return cache
+}
+
+// remove forgets the file cache of a node that was replaced
+func (c *fileCaches) remove(nodeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.nodes, nodeName)
+}
+
+// changedFiles returns the files of the cookbooks that aren't in the cache with
+// the same checksum and updates the cache as if they were downloaded
+func (cache nodeFileCache) changedFiles(ckbks cookbooks, eviction string, wipeRate float64) []cookbookFile {
+	if rand.Float64() < wipeRate {
+		for key := range cache {
+			delete(cache, key)
+		}
+	}
+
+	var (
+		changed []cookbookFile
+		used    = map[string]bool{}
+	)
+	for _, ckbk := range ckbks {
+		for _, ckbkFile := range ckbk.files() {
+			key := ckbk.CookbookName + "/" + ckbkFile.Path
+			used[key] = true
+			if cache[key] != ckbkFile.Checksum {
+				cache[key] = ckbkFile.Checksum
+				changed = append(changed, ckbkFile)
+			}
+		}
+	}
+
+	if eviction != neverCacheEviction {
+		for key := range cache {
+			if !used[key] {
+				delete(cache, key)
+			}
+		}
+	}
+	return changed
+}
+
+// downloadChanged downloads the files of the cookbooks that the node doesn't hold yet
+func (ckbks cookbooks) downloadChanged(nodeClient *chef.Client, nodeName string, config *Config, requests chan *request) {
+	cache := cookbookFileCaches.node(nodeName)
+	downloadFiles(nodeClient, nodeName, config, cache.changedFiles(ckbks, config.CookbookCacheEviction, config.CookbookCacheWipeRate), requests)
+}
@@ -0,0 +1,32 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeFileCacheChangedFiles(t *testing.T) {
+	v1 := cookbooks{"nginx": cookbook{CookbookName: "nginx", Recipes: []cookbookFile{
+		{Path: "recipes/default.rb", Checksum: "a"},
+		{Path: "recipes/install.rb", Checksum: "b"},
+	}}}
+	v2 := cookbooks{"nginx": cookbook{CookbookName: "nginx", Recipes: []cookbookFile{
+		{Path: "recipes/default.rb", Checksum: "a"},
+		{Path: "recipes/install.rb", Checksum: "c"},
+	}}}
+	other := cookbooks{"mysql": cookbook{CookbookName: "mysql", Recipes: []cookbookFile{
+		{Path: "recipes/default.rb", Checksum: "d"},
+	}}}
+
+	cache := nodeFileCache{}
+	assert.Len(t, cache.changedFiles(v1, staleCacheEviction, 0), 2)
+	assert.Len(t, cache.changedFiles(v1, staleCacheEviction, 0), 0)
+	assert.Equal(t, []cookbookFile{{Path: "recipes/install.rb", Checksum: "c"}}, cache.changedFiles(v2, staleCacheEviction, 0))
+	assert.Len(t, cache.changedFiles(v2, staleCacheEviction, 1), 2)
+
+	// Stale files are evicted unless the eviction is "never"
+	cache.changedFiles(other, staleCacheEviction, 0)
+	assert.Len(t, cache.changedFiles(v2, neverCacheEviction, 0), 2)
+	assert.Len(t, cache.changedFiles(other, neverCacheEviction, 0), 0)
+}
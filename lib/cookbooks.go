@@ -19,6 +19,7 @@ package chef_load
 
 import (
 	"math/rand"
+	"sync"
 
 	"github.com/go-chef/chef"
 )
@@ -44,20 +45,26 @@ type cookbook struct {
 	Resources    []cookbookFile `json:"resources"`
 	RootFiles    []cookbookFile `json:"root_files"`
 	Templates    []cookbookFile `json:"templates"`
+	// API version 1 lists every file in all_files instead of the segments above
+	AllFiles []cookbookFile `json:"all_files"`
 }
 
 type cookbooks map[string]cookbook
 
 // Note that go-chef provides the ability to download cookbooks, but we've kept our custom implementation
 // since that has our modifications to only download a percentage of total files
-func (ckbkFile cookbookFile) download(nodeClient *chef.Client, nodeName, chefVersion string, fileDlProbability float64, requests chan *request) {
-	if rand.Float64() < fileDlProbability {
-		apiRequest(*nodeClient, nodeName, chefVersion, "GET", ckbkFile.URL, nil, nil, nil, requests)
+func (ckbkFile cookbookFile) download(nodeClient *chef.Client, nodeName string, config *Config, requests chan *request) {
+	if config.DownloadCookbooksStream {
+		streamRequest(*nodeClient, nodeName, config.ChefVersion, ckbkFile.URL, requests)
+	} else {
+		apiRequest(*nodeClient, nodeName, config.ChefVersion, "GET", ckbkFile.URL, nil, nil, nil, requests)
 	}
 }
 
-func (ckbk cookbook) download(nodeClient *chef.Client, nodeName, chefVersion string, fileDlProbability float64, requests chan *request) {
-	for _, property := range []interface{}{
+// segments returns the file lists of the cookbook, the files of each list
+// can be changed in place
+func (ckbk cookbook) segments() [][]cookbookFile {
+	return [][]cookbookFile{
 		ckbk.Attributes,
 		ckbk.Definitions,
 		ckbk.Files,
@@ -67,15 +74,56 @@ func (ckbk cookbook) download(nodeClient *chef.Client, nodeName, chefVersion str
 		ckbk.Resources,
 		ckbk.RootFiles,
 		ckbk.Templates,
-	} {
-		for _, ckbkFile := range property.([]cookbookFile) {
-			ckbkFile.download(nodeClient, nodeName, chefVersion, fileDlProbability, requests)
-		}
+		ckbk.AllFiles,
+	}
+}
+
+// files returns every file of the cookbook
+func (ckbk cookbook) files() []cookbookFile {
+	var files []cookbookFile
+	for _, segment := range ckbk.segments() {
+		files = append(files, segment...)
 	}
+	return files
 }
 
-func (ckbks cookbooks) download(nodeClient *chef.Client, nodeName, chefVersion string, fileDlProbability float64, requests chan *request) {
+// download downloads each file of the cookbooks with probability fileDlProbability
+func (ckbks cookbooks) download(nodeClient *chef.Client, nodeName string, config *Config, fileDlProbability float64, requests chan *request) {
+	var files []cookbookFile
 	for _, ckbk := range ckbks {
-		ckbk.download(nodeClient, nodeName, chefVersion, fileDlProbability, requests)
+		for _, ckbkFile := range ckbk.files() {
+			if rand.Float64() < fileDlProbability {
+				files = append(files, ckbkFile)
+			}
+		}
+	}
+	downloadFiles(nodeClient, nodeName, config, files, requests)
+}
+
+// downloadFiles downloads the files with download_cookbooks_concurrency
+// parallel downloads, like chef-client does
+func downloadFiles(nodeClient *chef.Client, nodeName string, config *Config, files []cookbookFile, requests chan *request) {
+	concurrency := config.DownloadCookbooksConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg        sync.WaitGroup
+		filesChan = make(chan cookbookFile)
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ckbkFile := range filesChan {
+				ckbkFile.download(nodeClient, nodeName, config, requests)
+			}
+		}()
+	}
+	for _, ckbkFile := range files {
+		filesChan <- ckbkFile
 	}
+	close(filesChan)
+	wg.Wait()
 }
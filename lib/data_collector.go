@@ -20,6 +20,8 @@ package chef_load
 // Cheers! https://github.com/go-chef/chef/blob/master/http.go
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -40,6 +42,7 @@ type DataCollectorConfig struct {
 	URL     string
 	SkipSSL bool
 	Timeout time.Duration
+	Gzip    bool
 }
 
 // DataCollectorClient has our configured HTTP client, our Token and the URL
@@ -47,6 +50,7 @@ type DataCollectorClient struct {
 	Client   *http.Client
 	Token    string
 	URL      *url.URL
+	Gzip     bool
 	Requests chan *request
 }
 
@@ -72,6 +76,7 @@ func NewDataCollectorClient(cfg *DataCollectorConfig, reqChan chan *request) (*D
 		},
 		URL:      URL,
 		Token:    cfg.Token,
+		Gzip:     cfg.Gzip,
 		Requests: reqChan,
 	}
 	return c, nil
@@ -87,6 +92,12 @@ func (dcc *DataCollectorClient) Update(nodeName string, body interface{}) (*http
 		if err != nil {
 			return nil, err
 		}
+		if dcc.Gzip {
+			bodyJSON, err = gzipReader(bodyJSON)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Create an HTTP Request
@@ -97,6 +108,9 @@ func (dcc *DataCollectorClient) Update(nodeName string, body interface{}) (*http
 
 	// Set our headers
 	req.Header.Set("Content-Type", "application/json")
+	if dcc.Gzip && body != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	if dcc.Token != "dev" {
 		req.Header.Set("x-data-collector-auth", "version=1.0")
 		req.Header.Set("x-data-collector-token", dcc.Token)
@@ -139,6 +153,19 @@ func (dcc *DataCollectorClient) Update(nodeName string, body interface{}) (*http
 	return res, err
 }
 
+// gzipReader compresses the body to send it with "Content-Encoding: gzip"
+func gzipReader(body io.Reader) (io.Reader, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
 func chefAutomateSendMessage(client *DataCollectorClient, nodeName string, body interface{}) (int, error) {
 	code := 999
 	res, err := client.Update(nodeName, body)
@@ -160,7 +187,7 @@ func dataCollectorRunStart(config *Config, nodeName, chefServerFQDN, orgName str
 		"chef_server_fqdn":  chefServerFQDN,
 		"entity_uuid":       nodeUUID.String(),
 		"id":                runUUID.String(),
-		"message_version":   "1.1.0",
+		"message_version":   chefClientProfileFor(config.ChefVersion).messageVersion,
 		"message_type":      "run_start",
 		"node_name":         nodeName,
 		"organization_name": orgName,
@@ -220,7 +247,7 @@ func dataCollectorRunStop(config *Config, node chef.Node, nodeName, chefServerFQ
 		"chef_server_fqdn":       chefServerFQDN,
 		"entity_uuid":            nodeUUID.String(),
 		"id":                     runUUID.String(),
-		"message_version":        "1.1.0",
+		"message_version":        chefClientProfileFor(config.ChefVersion).messageVersion,
 		"message_type":           "run_converge",
 		"node_name":              nodeName,
 		"organization_name":      orgName,
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -34,9 +35,72 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func GenerateData(config *Config) error {
+// The generators of the generate command
+const (
+	CCRsGenerator       = "ccrs"
+	ActionsGenerator    = "actions"
+	ComplianceGenerator = "compliance"
+	LivenessGenerator   = "liveness"
+)
+
+// Generators are every generator in the order they are listed
+var Generators = []string{CCRsGenerator, ActionsGenerator, ComplianceGenerator, LivenessGenerator}
+
+// GeneratorProblem returns why the generator can't deliver any data with the
+// config, nil when it can
+func GeneratorProblem(config *Config, generator string) error {
+	switch generator {
+	case CCRsGenerator:
+		if config.DataCollectorURL == "" && !config.RunChefClient {
+			return errors.New("generating ccrs requires data_collector_url or chef_server_url")
+		}
+	case ActionsGenerator:
+		if config.DataCollectorURL == "" {
+			return errors.New("generating actions requires data_collector_url, actions are only sent to Chef Automate")
+		}
+	case ComplianceGenerator:
+		if config.DataCollectorURL == "" && !config.RunChefClient {
+			return errors.New("generating compliance reports requires data_collector_url or chef_server_url")
+		}
+	case LivenessGenerator:
+		if config.DataCollectorURL == "" {
+			return errors.New("generating liveness data requires data_collector_url, liveness pings are only sent to Chef Automate")
+		}
+	default:
+		return fmt.Errorf("unknown generator %q, it must be one of %s", generator, strings.Join(Generators, ", "))
+	}
+	return nil
+}
+
+// DefaultGenerators returns the generators that the generate command runs when
+// none is selected, the ones that can deliver data with the config
+func DefaultGenerators(config *Config) []string {
+	var generators []string
+	for _, generator := range Generators {
+		if generator == LivenessGenerator && !config.LivenessAgent {
+			continue
+		}
+		if err := GeneratorProblem(config, generator); err != nil {
+			log.WithField("reason", err).Warnf("Skipping the %s generator", generator)
+			continue
+		}
+		generators = append(generators, generator)
+	}
+	return generators
+}
+
+// GenerateData runs the generators in parallel and prints the profile of their
+// API requests, it refuses to run any generator that can't deliver its data
+func GenerateData(config *Config, generators []string) error {
+	for _, generator := range generators {
+		if err := GeneratorProblem(config, generator); err != nil {
+			return err
+		}
+	}
+
 	var (
 		numRequests = make(amountOfRequests)
+		numBytes    = make(bytesOfRequests)
 		requests    = make(chan *request)
 		startTime   = time.Now()
 	)
@@ -46,40 +110,64 @@ func GenerateData(config *Config) error {
 			select {
 			case req := <-requests:
 				numRequests.addRequest(request{Method: req.Method, Url: req.Url, StatusCode: req.StatusCode})
+				numBytes.addBytes(*req)
 			}
 		}
 	}()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		GenerateComplianceData(config, requests)
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		GenerateChefActions(config, requests)
-	}()
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		GenerateCCRs(config, requests)
-	}()
-	if config.LivenessAgent {
+	generatorFuncs := map[string]func(*Config, chan *request) error{
+		CCRsGenerator:       GenerateCCRs,
+		ActionsGenerator:    GenerateChefActions,
+		ComplianceGenerator: GenerateComplianceData,
+		LivenessGenerator:   GenerateLivenessData,
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, generator := range generators {
 		wg.Add(1)
-		go func() {
+		go func(generator string, generate func(*Config, chan *request) error) {
 			defer wg.Done()
-			GenerateLivenessData(config, requests)
-		}()
+			if err := generate(config, requests); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s generator: %s", generator, err))
+				mu.Unlock()
+			}
+		}(generator, generatorFuncs[generator])
 	}
 
 	wg.Wait()
 
-	printAPIRequestProfile(startTime, numRequests)
+	printAPIRequestProfile(startTime, numRequests, numBytes)
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// ValidateGenerate returns the problems of the config for the generators. The
+// sample reports are only checked when the compliance generator runs
+func ValidateGenerate(config *Config, generators []string) []error {
+	problems := ValidateConfig(config, hasGenerator(generators, ComplianceGenerator))
+	for _, generator := range generators {
+		if err := GeneratorProblem(config, generator); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	// ValidateConfig only checks the liveness settings of the liveness_agent
+	if hasGenerator(generators, LivenessGenerator) && !config.LivenessAgent {
+		problems = append(problems, validateLiveness(config)...)
+	}
+	return problems
+}
+
+func hasGenerator(generators []string, generator string) bool {
+	for _, g := range generators {
+		if g == generator {
+			return true
+		}
+	}
+	return false
 }
 
 func GenerateCCRs(config *Config, requests chan *request) (err error) {
@@ -318,7 +406,7 @@ func genRandomTags() []string {
 	return ts
 }
 
-func genStartEndTime(config *Config) (time.Time, time.Time) {
+func genStartEndTime(config *Config, nodeName string) (time.Time, time.Time) {
 	var (
 		sTime time.Time
 		eTime time.Time
@@ -330,9 +418,13 @@ func genStartEndTime(config *Config) (time.Time, time.Time) {
 	} else {
 		sTime = time.Now().UTC()
 	}
-	minutes := rand.Intn(60)
-	randDuration, _ := time.ParseDuration(fmt.Sprintf("%dm", minutes))
-	eTime = sTime.Add(randDuration).UTC()
+	if config.ConvergeDuration.enabled() {
+		eTime = sTime.Add(nodeConvergeDuration(config, nodeName)).UTC()
+	} else {
+		minutes := rand.Intn(60)
+		randDuration, _ := time.ParseDuration(fmt.Sprintf("%dm", minutes))
+		eTime = sTime.Add(randDuration).UTC()
+	}
 
 	return sTime, eTime
 }
@@ -349,8 +441,9 @@ func randAttributeMapKey(m map[string]interface{}) string {
 }
 
 func randomChefClientRun(config *Config, chefClient chef.Client, nodeName string, requests chan *request) (int, error) {
+	config = config.forNode(nodeName)
 	var (
-		startTime, endTime     = genStartEndTime(config)
+		startTime, endTime     = genStartEndTime(config, nodeName)
 		runUUID                = uuid.New()
 		nodeUUID               = uuid.NewMD5(uuid.NameSpaceDNS, []byte(nodeName))
 		orgName                = getRandom("organization")
@@ -382,6 +475,15 @@ func randomChefClientRun(config *Config, chefClient chef.Client, nodeName string
 	//"platform_family": "rhel",
 
 	node.AutomaticAttributes["recipes"] = randRecipes
+	if config.UnifiedNodes {
+		unifiedNode := newUnifiedNode(config, nodeName)
+		node.Environment = unifiedNode.environment
+		node.RunList = unifiedNode.runList.toStringSlice()
+		unifiedNode.setAutomaticAttributes(node.AutomaticAttributes)
+		nodeUUID = unifiedNode.nodeUUID
+		orgName = unifiedNode.orgName
+		chefServerFQDN = unifiedNode.sourceFqdn
+	}
 	node.AutomaticAttributes["cookbooks"] = map[string]interface{}{}
 	node.AutomaticAttributes["uptime_seconds"] = 0
 	node.NormalAttributes = genRandomAttributes()
@@ -390,15 +492,7 @@ func randomChefClientRun(config *Config, chefClient chef.Client, nodeName string
 	runList := parseRunList(node.RunList)
 
 	if config.RunChefClient {
-		clientBody := map[string]interface{}{
-			"admin":     false,
-			"name":      nodeName,
-			"validator": false,
-		}
-		if config.ChefServerCreatesClientKey {
-			clientBody["create_key"] = config.ChefServerCreatesClientKey
-		}
-		apiRequest(chefClient, nodeName, config.ChefVersion, "POST", "clients", clientBody, nil, nil, requests)
+		apiRequest(chefClient, nodeName, config.ChefVersion, "POST", "clients", clientCreateBody(config, nodeName), nil, nil, requests)
 
 		res, _ := apiRequest(chefClient, nodeName, config.ChefVersion, "GET", "nodes/"+nodeName, nil, &node, nil, requests)
 		if res != nil && res.StatusCode == 404 {
@@ -408,7 +502,11 @@ func randomChefClientRun(config *Config, chefClient chef.Client, nodeName string
 
 	if config.RunChefClient {
 		// Expand run_list
-		expandedRunList = runList.expand(&chefClient, nodeName, config.ChefVersion, node.Environment, requests)
+		var err error
+		expandedRunList, err = runList.expand(&chefClient, nodeName, config.ChefVersion, node.Environment, nil, requests)
+		if err != nil {
+			status = "failure"
+		}
 
 		// TODO Check error?
 		apiRequest(chefClient, nodeName, config.ChefVersion, "GET", "environments/"+node.Environment, nil, nil, nil, requests)
@@ -446,18 +544,27 @@ func randomChefClientRun(config *Config, chefClient chef.Client, nodeName string
 		// This behaves differently than client run because we don't track a first run here.
 		// TODO - move the download_cookbooks_scale_factor to a) a better name, b) the 'run' command
 		if config.DownloadCookbooks == "always" || (config.DownloadCookbooks == "first") {
-			ckbks.download(&chefClient, nodeName, config.ChefVersion, config.DownloadCookbooksScaleFactor, requests)
+			ckbks.download(&chefClient, nodeName, config, config.DownloadCookbooksScaleFactor, requests)
+		} else if config.DownloadCookbooks == "cache" {
+			ckbks.downloadChanged(&chefClient, nodeName, config, requests)
 		}
+		vars := templateVars{nodeName: nodeName, environment: node.Environment, roles: runList.roleNames()}
+		for _, cookbookRequest := range config.APIRequests {
+			if !cookbookRequest.request(chefClient, nodeName, config.ChefVersion, vars, requests) {
+				status = "failure"
+			}
+		}
+		runWorkload(config.Workload, chefClient, nodeName, config.ChefVersion, vars, requests)
 	} else {
 		expandedRunList = runList.toStringSlice()
 	}
 
 	if config.RunChefClient {
-		apiRequest(chefClient, nodeName, config.ChefVersion, "PUT", "nodes/"+nodeName, node, nil, nil, requests)
+		apiRequest(chefClient, nodeName, config.ChefVersion, "PUT", "nodes/"+nodeName, nodeSaveBody(config.ChefVersion, node), nil, nil, requests)
 
 		// Notify Reporting of run end
 		if config.EnableReporting && reportingAvailable {
-			reportingRunStop(chefClient, nodeName, config.ChefVersion, runUUID, startTime, endTime, runList, requests)
+			reportingRunStop(config, chefClient, nodeName, runUUID, startTime, endTime, status, runList, expandedRunList, requests)
 		}
 	}
 
@@ -475,23 +582,38 @@ func randomChefClientRun(config *Config, chefClient chef.Client, nodeName string
 	ccrAction.SetTask(updateTask)
 	ccrAction.EntityName = nodeName
 	ccrAction.RequestorName = nodeName
+	ccrAction.RequestorType = "client"
+	ccrAction.setData(node.Environment, node.RunList)
 	if config.DataCollectorURL != "" {
 		code, err = chefAutomateSendMessage(dataCollectorClient, ccrAction.String(), ccrAction)
 	} else if dataCollectorAvailable {
 		apiRequest(chefClient, ccrAction.String(), config.ChefVersion, "POST", "data-collector", ccrAction, nil, nil, requests)
 	}
 
-	// TODO: (@afiune) Notify Data Collector of compliance report
-	//reportUUID, _ := uuid.NewV4()
-	//
-	//if len(config.ComplianceStatusJSONFile) != 0 {
-	//	complianceJSON := parseJSONFile(config.ComplianceStatusJSONFile)
-	//	complianceReportBody := dataCollectorComplianceReport(nodeName, "chefEnvironment", reportUUID, nodeUUID, endTime, complianceJSON)
-	//	if config.DataCollectorURL != "" {
-	//		chefAutomateSendMessage(dataCollectorClient, nodeName, complianceReportBody)
-	//	} else {
-	//		apiRequest(chefClient, nodeName, config.ChefVersion, "POST", "data-collector", complianceReportBody, nil, nil, requests)
-	//	}
-	//}
+	// Notify Data Collector of compliance report
+	if config.ComplianceStatusJSONFile != "" {
+		roles, _ := node.AutomaticAttributes["roles"].([]string)
+		recipes, _ := node.AutomaticAttributes["recipes"].([]string)
+		chefTags, _ := node.NormalAttributes["tags"].([]string)
+		nodeDetails := NodeDetails{
+			name:        nodeName,
+			ipAddr:      stringValue(node.AutomaticAttributes["ipaddress"]),
+			environment: node.Environment,
+			roles:       roles,
+			recipes:     recipes,
+			nodeUUID:    nodeUUID,
+			platform:    stringValue(node.AutomaticAttributes["platform"]),
+			sourceFqdn:  chefServerFQDN,
+			fqdn:        nodeName,
+			orgName:     orgName,
+			chefTags:    chefTags,
+		}
+		complianceReportBody := dataCollectorComplianceReport(nodeDetails, uuid.New(), endTime, parseJSONFile(config.ComplianceStatusJSONFile))
+		if config.DataCollectorURL != "" {
+			chefAutomateSendMessage(dataCollectorClient, nodeName, complianceReportBody)
+		} else if dataCollectorAvailable {
+			apiRequest(chefClient, nodeName, config.ChefVersion, "POST", "data-collector", complianceReportBody, nil, nil, requests)
+		}
+	}
 	return code, err
 }
@@ -0,0 +1,58 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratorProblem(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/organizations/demo/"
+	config.RunChefClient = true
+
+	// Only a Chef Server
+	assert.Nil(t, GeneratorProblem(&config, CCRsGenerator))
+	assert.Nil(t, GeneratorProblem(&config, ComplianceGenerator))
+	assert.NotNil(t, GeneratorProblem(&config, ActionsGenerator))
+	assert.NotNil(t, GeneratorProblem(&config, LivenessGenerator))
+	assert.NotNil(t, GeneratorProblem(&config, "nodes"))
+	assert.Equal(t, []string{CCRsGenerator, ComplianceGenerator}, DefaultGenerators(&config))
+	assert.NotNil(t, GenerateData(&config, []string{ActionsGenerator}))
+
+	config.DataCollectorURL = "https://automate.example.com/data-collector/v0/"
+	assert.Nil(t, GeneratorProblem(&config, ActionsGenerator))
+	assert.Equal(t, []string{CCRsGenerator, ActionsGenerator, ComplianceGenerator}, DefaultGenerators(&config))
+
+	config.LivenessAgent = true
+	assert.Equal(t, Generators, DefaultGenerators(&config))
+}
+
+func TestValidateGenerate(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/organizations/demo/"
+	config.DataCollectorURL = "https://automate.example.com/data-collector/v0/"
+	// The compliance samples are missing
+	config.Matrix.Simulation.Nodes = 50
+	config.ComplianceSampleReportsDir = "../sample-data/missing-reports"
+
+	assert.Empty(t, ValidateGenerate(&config, []string{CCRsGenerator}))
+	assert.NotEmpty(t, ValidateGenerate(&config, []string{ComplianceGenerator}))
+
+	// The liveness generator spaces its pings with the liveness settings
+	config.LivenessInterval = 0
+	assert.Empty(t, ValidateGenerate(&config, []string{ActionsGenerator}))
+	assert.Len(t, ValidateGenerate(&config, []string{LivenessGenerator}), 1)
+}
+
+func TestGenerateDataReturnsGeneratorErrors(t *testing.T) {
+	config := Default()
+	config.DataCollectorURL = "https://automate.example.com/data-collector/v0/"
+	config.NumNodes = 1
+	// The liveness generator fails before it sends any ping
+	config.ChefServerURL = ""
+
+	err := GenerateData(&config, []string{LivenessGenerator})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "liveness generator")
+}
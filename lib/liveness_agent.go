@@ -41,9 +41,9 @@ type LivenessRequest struct {
 	Timestamp        time.Time `json:"@timestamp"`
 }
 
-func newLivenessPingRequest(nodeName, chefServerFQDN, chefServerOrg string) *LivenessRequest {
+func newLivenessPingRequest(nodeName, chefServerFQDN, chefServerOrg string, timestamp time.Time) *LivenessRequest {
 	return &LivenessRequest{
-		Timestamp:        time.Now(),
+		Timestamp:        timestamp,
 		Source:           "liveness_agent",
 		EventType:        "node_ping",
 		MessageVersion:   "0.0.1",
@@ -58,9 +58,34 @@ func (lr *LivenessRequest) String() string {
 	return fmt.Sprintf("%s::%s", lr.EventType, lr.NodeName)
 }
 
+// livenessNodeNames returns the name of every node that runs the liveness agent,
+// first the converging nodes and then the nodes that only send liveness pings
+func livenessNodeNames(config *Config) []string {
+	nodeNames := make([]string, 0, config.NumNodes+config.LivenessOnlyNodes)
+	for i := 1; i <= config.NumNodes; i++ {
+		nodeNames = append(nodeNames, config.NodeNamePrefix+"-"+strconv.Itoa(i))
+	}
+	for i := 1; i <= config.LivenessOnlyNodes; i++ {
+		nodeNames = append(nodeNames, config.NodeNamePrefix+"-liveness-"+strconv.Itoa(i))
+	}
+	return nodeNames
+}
+
+// jitter randomly shifts the provided duration up to +/- the provided fraction of itself
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*fraction*float64(d))
+}
+
 func GenerateLivenessData(config *Config, requests chan *request) error {
+	nodeNames := livenessNodeNames(config)
 	log.WithFields(log.Fields{
-		"nodes": config.NumNodes,
+		"nodes":             len(nodeNames),
+		"liveness_only":     config.LivenessOnlyNodes,
+		"days_back":         config.DaysBack,
+		"liveness_interval": config.LivenessInterval,
 	}).Info("Generating liveness agent data")
 
 	rand.Seed(time.Now().UTC().UnixNano())
@@ -79,18 +104,79 @@ func GenerateLivenessData(config *Config, requests chan *request) error {
 		return errors.New(fmt.Sprintf("Error parsing ChefServer URL: %+v \n", err))
 	}
 
-	for i := 1; i <= config.NumNodes; i++ {
-		nodeName := config.NodeNamePrefix + "-" + strconv.Itoa(i)
-		livenessPing(nodeName, chefServerURL, dataCollectorClient)
+	var (
+		now          = time.Now().UTC()
+		channels     []<-chan int
+		pingsSent    int64
+		pingsRejects int64
+		timeMarker   = time.Now()
+	)
+
+	// Send the pings of the nodes in batches of config.Threads goroutines
+	for i := 0; i < len(nodeNames); i += config.Threads {
+		channels = make([]<-chan int, min(config.Threads, len(nodeNames)-i))
+		for j := range channels {
+			channels[j] = asyncLivenessPings(config, nodeNames[i+j], chefServerURL, dataCollectorClient, now)
+		}
+
+		rejects := false
+		for code := range merge(channels...) {
+			pingsSent++
+			if code != 200 {
+				rejects = true
+				pingsRejects++
+			}
+		}
+
+		// When we start rejecting/dropping messages we will wait
+		// an interval of time to let the system digest
+		if rejects {
+			log.WithFields(log.Fields{
+				"total_nodes":                       len(nodeNames),
+				"nodes_processed":                   i + len(channels),
+				"sleep":                             fmt.Sprintf("%ds", config.SleepTimeOnFailure),
+				"time_elapsed_since_last_failure":   time.Now().Sub(timeMarker),
+				"pings_sent_since_last_failure":     pingsSent,
+				"pings_rejected_since_last_failure": pingsRejects,
+				"goroutines":                        config.Threads,
+				"days_back":                         config.DaysBack,
+			}).Info("Sleeping")
+			time.Sleep(time.Second * time.Duration(config.SleepTimeOnFailure))
+
+			pingsSent = 0
+			pingsRejects = 0
+			timeMarker = time.Now()
+		}
 	}
 	return nil
 }
 
-func livenessPing(nodeName string, chefServerURL *url.URL, dataCollectorClient *DataCollectorClient) (int, error) {
+// asyncLivenessPings sends the pings of the node, backfilling its liveness
+// history from days_back until now, and the status code of every ping
+func asyncLivenessPings(config *Config, nodeName string, chefServerURL *url.URL, dataCollectorClient *DataCollectorClient, now time.Time) <-chan int {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		interval := time.Duration(config.LivenessInterval) * time.Minute
+		if config.DaysBack > 0 {
+			pingTime := now.AddDate(0, 0, -config.DaysBack)
+			for pingTime.Before(now) {
+				code, _ := livenessPing(nodeName, chefServerURL, dataCollectorClient, pingTime)
+				out <- code
+				pingTime = pingTime.Add(jitter(interval, config.LivenessJitter))
+			}
+		}
+		code, _ := livenessPing(nodeName, chefServerURL, dataCollectorClient, now)
+		out <- code
+	}()
+	return out
+}
+
+func livenessPing(nodeName string, chefServerURL *url.URL, dataCollectorClient *DataCollectorClient, timestamp time.Time) (int, error) {
 	var (
 		chefServerFQDN = chefServerURL.Host
 		chefServerOrg  = strings.Split(chefServerURL.Path, "/")[2]
 	)
-	lvPing := newLivenessPingRequest(nodeName, chefServerFQDN, chefServerOrg)
+	lvPing := newLivenessPingRequest(nodeName, chefServerFQDN, chefServerOrg, timestamp)
 	return chefAutomateSendMessage(dataCollectorClient, lvPing.String(), lvPing)
 }
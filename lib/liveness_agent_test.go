@@ -0,0 +1,25 @@
+package chef_load
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLivenessNodeNames(t *testing.T) {
+	config := Default()
+	config.NumNodes = 2
+	config.LivenessOnlyNodes = 1
+
+	assert.Equal(t, []string{"chef-load-1", "chef-load-2", "chef-load-liveness-1"}, livenessNodeNames(&config))
+}
+
+func TestJitter(t *testing.T) {
+	assert.Equal(t, time.Minute, jitter(time.Minute, 0))
+
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Minute, 0.1)
+		assert.True(t, d >= 54*time.Second && d <= 66*time.Second, "jitter out of bounds: %s", d)
+	}
+}
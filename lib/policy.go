@@ -0,0 +1,61 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+import (
+	"github.com/go-chef/chef"
+)
+
+type cookbookLock struct {
+	Version    string `json:"version"`
+	Identifier string `json:"identifier"`
+}
+
+// policy is the revision of a policy that is active in a policy group
+type policy struct {
+	Name          string                  `json:"name"`
+	RevisionID    string                  `json:"revision_id"`
+	RunList       []string                `json:"run_list"`
+	CookbookLocks map[string]cookbookLock `json:"cookbook_locks"`
+}
+
+// loadPolicy gets the policy revision that the node converges, policy nodes
+// request it instead of their roles and environment
+func loadPolicy(nodeClient *chef.Client, nodeName, chefVersion, policyGroup, policyName string, requests chan *request) (policy, error) {
+	var p policy
+	_, err := apiRequest(*nodeClient, nodeName, chefVersion, "GET", "policy_groups/"+policyGroup+"/policies/"+policyName, nil, &p, nil, requests)
+	return p, err
+}
+
+// cookbooks gets the cookbook artifacts that the policy locks, policy nodes
+// request them instead of solving their run_list dependencies
+func (p policy) cookbooks(nodeClient *chef.Client, nodeName, chefVersion string, requests chan *request) cookbooks {
+	ckbks := cookbooks{}
+	for name, lock := range p.CookbookLocks {
+		var ckbk cookbook
+		_, err := apiRequest(*nodeClient, nodeName, chefVersion, "GET", "cookbook_artifacts/"+name+"/"+lock.Identifier, nil, &ckbk, nil, requests)
+		if err != nil {
+			continue
+		}
+		if ckbk.CookbookName == "" {
+			ckbk.CookbookName = name
+		}
+		ckbks[name] = ckbk
+	}
+	return ckbks
+}
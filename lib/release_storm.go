@@ -0,0 +1,221 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+// This file simulates the release of new cookbook versions in the start mode.
+// The released cookbooks are changed in the dependencies that the Chef Server
+// solves for every node, so the next run of every node downloads their changed files
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chef/chef"
+	log "github.com/sirupsen/logrus"
+)
+
+// cookbookRelease holds the fraction of the files of a cookbook that each of
+// its releases changed
+type cookbookRelease struct {
+	changedFiles []float64
+}
+
+type cookbookReleases struct {
+	mu        sync.RWMutex
+	cookbooks map[string]cookbookRelease
+}
+
+var releasedCookbooks = &cookbookReleases{cookbooks: map[string]cookbookRelease{}}
+
+// release marks the cookbooks as changed
+func (r *cookbookReleases) release(cookbookNames []string, changedFiles float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cookbookName := range cookbookNames {
+		release := r.cookbooks[cookbookName]
+		release.changedFiles = append(release.changedFiles, changedFiles)
+		r.cookbooks[cookbookName] = release
+	}
+}
+
+// apply bumps the version of the released cookbooks and changes the checksum
+// of the files that each of their releases changed
+func (r *cookbookReleases) apply(ckbks cookbooks) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name, ckbk := range ckbks {
+		release, ok := r.cookbooks[ckbk.CookbookName]
+		if !ok {
+			continue
+		}
+		ckbk.Version = bumpVersion(ckbk.Version, len(release.changedFiles))
+		for _, files := range ckbk.segments() {
+			for i := range files {
+				files[i].Checksum = releasedChecksum(files[i], release)
+			}
+		}
+		ckbks[name] = ckbk
+	}
+}
+
+// releasedChecksum returns the checksum of the file after the releases, every
+// release changes the file with the probability of its changedFiles
+func releasedChecksum(ckbkFile cookbookFile, release cookbookRelease) string {
+	checksum := ckbkFile.Checksum
+	for i, changedFiles := range release.changedFiles {
+		h := fnv.New32a()
+		h.Write([]byte(ckbkFile.Path + "/" + strconv.Itoa(i+1)))
+		if float64(h.Sum32())/float64(1<<32) < changedFiles {
+			checksum = fmt.Sprintf("%s-%d", ckbkFile.Checksum, i+1)
+		}
+	}
+	return checksum
+}
+
+// bumpVersion increases the patch level of the version
+func bumpVersion(version string, releases int) string {
+	parts := strings.Split(version, ".")
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	patch, _ := strconv.Atoi(parts[2])
+	parts[2] = strconv.Itoa(patch + releases)
+	return strings.Join(parts, ".")
+}
+
+// runListCookbooks returns the cookbooks of the recipes of the run lists, the
+// ones that every node resolves no matter what its roles hold
+func runListCookbooks(config *Config) []string {
+	runLists := parseRunLists(config.RunLists)
+	if len(runLists) == 0 {
+		runLists = []runList{parseRunList(config.RunList)}
+	}
+
+	var cookbookNames []string
+	seen := map[string]bool{}
+	for _, rl := range runLists {
+		for _, rli := range rl {
+			cookbookName := strings.Split(rli.name, "::")[0]
+			if rli.itemType == "recipe" && !seen[cookbookName] {
+				seen[cookbookName] = true
+				cookbookNames = append(cookbookNames, cookbookName)
+			}
+		}
+	}
+	return cookbookNames
+}
+
+// releaseStormCookbooks returns the cookbooks to release, the configured ones
+// or count random cookbooks of the run lists
+func releaseStormCookbooks(config *Config) []string {
+	if len(config.ReleaseStorm.Cookbooks) > 0 {
+		return config.ReleaseStorm.Cookbooks
+	}
+	cookbookNames := runListCookbooks(config)
+	rand.Shuffle(len(cookbookNames), func(i, j int) { cookbookNames[i], cookbookNames[j] = cookbookNames[j], cookbookNames[i] })
+	if count := config.ReleaseStorm.Count; count > 0 && count < len(cookbookNames) {
+		cookbookNames = cookbookNames[:count]
+	}
+	return cookbookNames
+}
+
+// requestPhase aggregates the API requests made during a phase of the load
+type requestPhase struct {
+	name      string
+	startTime time.Time
+	requests  amountOfRequests
+	bytes     bytesOfRequests
+}
+
+func newRequestPhase(name string) *requestPhase {
+	return &requestPhase{
+		name:      name,
+		startTime: time.Now(),
+		requests:  make(amountOfRequests),
+		bytes:     make(bytesOfRequests),
+	}
+}
+
+func (p *requestPhase) addRequest(req request) {
+	p.requests.addRequest(request{Method: req.Method, Url: req.Url, StatusCode: req.StatusCode})
+	p.bytes.addBytes(req)
+}
+
+func (p *requestPhase) print() {
+	log.WithField("phase", p.name).Info("Phase finished")
+	printAPIRequestProfile(p.startTime, p.requests, p.bytes)
+}
+
+// serverCookbookVersion gets the latest version of the cookbook on the Chef
+// Server, the one that the nodes resolve unless their run list pins another
+func serverCookbookVersion(chefClient chef.Client, config *Config, cookbookName string, requests chan *request) (string, error) {
+	var versions map[string]struct {
+		Versions []struct {
+			Version string `json:"version"`
+		} `json:"versions"`
+	}
+	_, err := apiRequest(chefClient, config.ClientName, config.ChefVersion, "GET", "cookbooks/"+cookbookName, nil, &versions, nil, requests)
+	if err != nil {
+		return "", err
+	}
+	// The Chef Server lists the versions from the newest to the oldest
+	if cookbookVersions := versions[cookbookName].Versions; len(cookbookVersions) > 0 {
+		return cookbookVersions[0].Version, nil
+	}
+	return "", fmt.Errorf("cookbook %s has no versions", cookbookName)
+}
+
+// startReleaseStorm waits release_storm.after minutes, releases the cookbooks
+// and sends their version create actions. The next interval, in which every
+// node picks up the release, is reported as its own phase: phases receives
+// the phase when it starts and nil when it ends
+func startReleaseStorm(config *Config, dataCollectorClient *DataCollectorClient, phases chan<- *requestPhase, requests chan *request) {
+	time.Sleep(time.Duration(config.ReleaseStorm.After) * time.Minute)
+
+	cookbookNames := releaseStormCookbooks(config)
+	releasedCookbooks.release(cookbookNames, config.ReleaseStorm.ChangedFiles)
+	log.WithFields(log.Fields{
+		"cookbooks":     cookbookNames,
+		"changed_files": config.ReleaseStorm.ChangedFiles,
+	}).Info("Releasing new cookbook versions")
+
+	if dataCollectorClient != nil {
+		simulation := newActionSimulation(config)
+		chefClient := getAPIClient(config.ClientName, config.ClientKey, config.ChefServerURL)
+		for _, cookbookName := range cookbookNames {
+			version, err := serverCookbookVersion(chefClient, config, cookbookName, requests)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"cookbook": cookbookName,
+					"error":    err,
+				}).Warn("Could not get the cookbook version, skipping its version create action")
+				continue
+			}
+			sendChefAction(simulation.cookbookReleaseAction(cookbookName, version), dataCollectorClient)
+		}
+	}
+
+	phases <- newRequestPhase("cookbook release storm")
+	time.Sleep(time.Duration(config.Interval) * time.Minute)
+	phases <- nil
+}
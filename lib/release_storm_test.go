@@ -0,0 +1,58 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookbookReleasesApply(t *testing.T) {
+	solved := func() cookbooks {
+		return cookbooks{
+			"nginx": cookbook{CookbookName: "nginx", Version: "1.2.3", Recipes: []cookbookFile{
+				{Path: "recipes/default.rb", Checksum: "a"},
+				{Path: "recipes/install.rb", Checksum: "b"},
+			}},
+			"mysql": cookbook{CookbookName: "mysql", Version: "2.0.0", Recipes: []cookbookFile{
+				{Path: "recipes/default.rb", Checksum: "c"},
+			}},
+		}
+	}
+	releases := &cookbookReleases{cookbooks: map[string]cookbookRelease{}}
+
+	releases.release([]string{"nginx"}, 1.0)
+	ckbks := solved()
+	releases.apply(ckbks)
+	assert.Equal(t, "1.2.4", ckbks["nginx"].Version)
+	assert.Equal(t, "a-1", ckbks["nginx"].Recipes[0].Checksum)
+	assert.Equal(t, "2.0.0", ckbks["mysql"].Version)
+	assert.Equal(t, "c", ckbks["mysql"].Recipes[0].Checksum)
+
+	// Nodes that already hold the release only download the files of the next one
+	cache := nodeFileCache{}
+	cache.changedFiles(ckbks, staleCacheEviction, 0)
+	releases.release([]string{"nginx"}, 0.0)
+	ckbks = solved()
+	releases.apply(ckbks)
+	assert.Equal(t, "1.2.5", ckbks["nginx"].Version)
+	assert.Empty(t, cache.changedFiles(ckbks, staleCacheEviction, 0))
+}
+
+func TestBumpVersion(t *testing.T) {
+	assert.Equal(t, "1.2.5", bumpVersion("1.2.3", 2))
+	assert.Equal(t, "1.0.1", bumpVersion("1", 1))
+}
+
+func TestReleaseStormCookbooks(t *testing.T) {
+	config := Default()
+	config.RunLists = [][]string{{"recipe[nginx::install]", "role[web]"}, {"nginx", "mysql@2.0.0"}}
+	config.ReleaseStorm.Count = 5
+	assert.ElementsMatch(t, []string{"nginx", "mysql"}, releaseStormCookbooks(&config))
+
+	// Role only run lists have no cookbooks that every node resolves
+	config.RunLists = [][]string{{"role[web]"}}
+	assert.Empty(t, releaseStormCookbooks(&config))
+
+	config.ReleaseStorm.Cookbooks = []string{"apache2"}
+	assert.Equal(t, []string{"apache2"}, releaseStormCookbooks(&config))
+}
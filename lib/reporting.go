@@ -18,7 +18,10 @@
 package chef_load
 
 import (
+	"fmt"
+	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,18 +42,148 @@ func reportingRunStart(nodeClient chef.Client, nodeName, chefVersion string, run
 	return res, err
 }
 
-func reportingRunStop(nodeClient chef.Client, nodeName, chefVersion string, runUUID uuid.UUID, startTime time.Time, endTime time.Time, rl runList, requests chan *request) (*http.Response, error) {
+// reportingRunStop sends the end of the run with reporting_updated_resources updated
+// resources out of reporting_total_resources, failed runs include the exception
+func reportingRunStop(config *Config, nodeClient chef.Client, nodeName string, runUUID uuid.UUID, startTime time.Time, endTime time.Time, status string, rl runList, expandedRunList []string, requests chan *request) (*http.Response, error) {
+	totalResources := config.ReportingTotalResources
+	updatedResources := config.ReportingUpdatedResources
+	if updatedResources > totalResources {
+		totalResources = updatedResources
+	}
+
+	resources := make([]interface{}, updatedResources)
+	for i := range resources {
+		failed := status == "failure" && i == len(resources)-1
+		resources[i] = reportingResource(expandedRunList, i, failed)
+	}
+
+	data := map[string]interface{}{}
+	if status == "failure" {
+		data["exception"] = reportingException(resources)
+	}
+
 	body := map[string]interface{}{
 		"action":          "end",
-		"data":            map[string]interface{}{},
+		"data":            data,
 		"end_time":        endTime.Format(rubyDateTime),
-		"resources":       []interface{}{},
+		"resources":       resources,
 		"run_list":        `["` + strings.Join(rl.toStringSlice(), `","`) + `"]`,
 		"start_time":      startTime.Format(rubyDateTime),
-		"status":          "success",
-		"total_res_count": "0",
+		"status":          status,
+		"total_res_count": strconv.Itoa(totalResources),
 	}
 
-	res, err := apiRequest(nodeClient, nodeName, chefVersion, "POST", "reports/nodes/"+nodeName+"/runs/"+runUUID.String(), body, nil, map[string]string{"X-Ops-Reporting-Protocol-Version": "0.1.0"}, requests)
+	res, err := apiRequest(nodeClient, nodeName, config.ChefVersion, "POST", "reports/nodes/"+nodeName+"/runs/"+runUUID.String(), body, nil, map[string]string{"X-Ops-Reporting-Protocol-Version": "0.1.0"}, requests)
 	return res, err
 }
+
+// The resources of the Reporting runs, with the action they take and the
+// state of the resource before and after it
+var reportingResourceTypes = []struct {
+	resourceType string
+	name         string
+	result       string
+	before       map[string]interface{}
+	after        map[string]interface{}
+	delta        string
+}{
+	{
+		resourceType: "template",
+		name:         "/etc/app/app%d.conf",
+		result:       "create",
+		before:       map[string]interface{}{"owner": "root", "group": "root", "mode": "0644", "checksum": "3c9fc0bd0ebf6a4e4b5d8f1a2d1c30e2"},
+		after:        map[string]interface{}{"owner": "root", "group": "root", "mode": "0644", "checksum": "9b2cf535f27731c974343645a3985328"},
+		delta:        "--- /etc/app/app.conf\n+++ /etc/app/.chef-app.conf\n@@ -1 +1 @@\n-workers = 4\n+workers = 8",
+	},
+	{
+		resourceType: "package",
+		name:         "app-package-%d",
+		result:       "install",
+		before:       map[string]interface{}{"package_name": "app-package", "version": nil},
+		after:        map[string]interface{}{"package_name": "app-package", "version": "1.2.3-1"},
+	},
+	{
+		resourceType: "service",
+		name:         "app-service-%d",
+		result:       "restart",
+		before:       map[string]interface{}{"service_name": "app-service", "enabled": true, "running": true},
+		after:        map[string]interface{}{"service_name": "app-service", "enabled": true, "running": true},
+	},
+	{
+		resourceType: "file",
+		name:         "/var/lib/app/state%d",
+		result:       "create",
+		before:       map[string]interface{}{},
+		after:        map[string]interface{}{"owner": "app", "group": "app", "mode": "0600", "checksum": "e3b0c44298fc1c149afbf4c8996fb924"},
+		delta:        "--- /var/lib/app/state\n+++ /var/lib/app/.chef-state\n@@ -0,0 +1 @@\n+ready",
+	},
+	{
+		resourceType: "execute",
+		name:         "run migration %d",
+		result:       "run",
+		before:       map[string]interface{}{},
+		after:        map[string]interface{}{"command": "/opt/app/bin/migrate"},
+	},
+}
+
+// reportingResource returns the index-th updated resource of the run, declared
+// by one of the cookbooks of the expanded run list
+func reportingResource(expandedRunList []string, index int, failed bool) map[string]interface{} {
+	resourceType := reportingResourceTypes[rand.Intn(len(reportingResourceTypes))]
+	name := fmt.Sprintf(resourceType.name, index+1)
+
+	cookbookName, cookbookVersion := "chef-load", randomCookbookVersion()
+	if len(expandedRunList) > 0 {
+		recipe := strings.SplitN(expandedRunList[rand.Intn(len(expandedRunList))], "@", 2)
+		cookbookName = strings.Split(recipe[0], "::")[0]
+		if len(recipe) == 2 {
+			cookbookVersion = recipe[1]
+		}
+	}
+
+	resource := map[string]interface{}{
+		"type":             resourceType.resourceType,
+		"name":             name,
+		"id":               name,
+		"before":           resourceType.before,
+		"after":            resourceType.after,
+		"duration":         strconv.Itoa(rand.Intn(2000)),
+		"delta":            resourceType.delta,
+		"ignore_failure":   false,
+		"result":           resourceType.result,
+		"status":           "success",
+		"cookbook_name":    cookbookName,
+		"cookbook_version": cookbookVersion,
+	}
+	if failed {
+		resource["status"] = "failed"
+		resource["after"] = resourceType.before
+		resource["delta"] = ""
+	}
+	return resource
+}
+
+// reportingException describes the failure of the last resource of the run like chef-client does
+func reportingException(resources []interface{}) map[string]interface{} {
+	title := "Error executing run"
+	if len(resources) > 0 {
+		resource := resources[len(resources)-1].(map[string]interface{})
+		title = fmt.Sprintf("Error executing action `%s` on resource '%s[%s]'", resource["result"], resource["type"], resource["name"])
+	}
+	return map[string]interface{}{
+		"class":   "Mixlib::ShellOut::ShellCommandFailed",
+		"message": "Expected process to exit with [0], but received '1'",
+		"backtrace": []string{
+			"/opt/chef/embedded/lib/ruby/gems/2.4.0/gems/mixlib-shellout-2.3.2/lib/mixlib/shellout.rb:289:in `invalid!'",
+			"/opt/chef/embedded/lib/ruby/gems/2.4.0/gems/mixlib-shellout-2.3.2/lib/mixlib/shellout.rb:276:in `error!'",
+			"/opt/chef/embedded/lib/ruby/gems/2.4.0/gems/chef-13.2.20/lib/chef/mixin/shell_out.rb:166:in `shell_out!'",
+		},
+		"description": map[string]interface{}{
+			"title": title,
+			"sections": []interface{}{
+				map[string]interface{}{"Mixlib::ShellOut::ShellCommandFailed": "Expected process to exit with [0], but received '1'"},
+				map[string]interface{}{"Platform": "x86_64-linux"},
+			},
+		},
+	}
+}
@@ -0,0 +1,22 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportingResource(t *testing.T) {
+	resource := reportingResource([]string{"nginx::default@1.2.3"}, 0, false)
+	assert.Equal(t, "nginx", resource["cookbook_name"])
+	assert.Equal(t, "1.2.3", resource["cookbook_version"])
+	assert.Equal(t, "success", resource["status"])
+	assert.Equal(t, resource["name"], resource["id"])
+
+	failed := reportingResource([]string{"nginx"}, 1, true)
+	assert.Equal(t, "nginx", failed["cookbook_name"])
+	assert.Equal(t, "failed", failed["status"])
+
+	exception := reportingException([]interface{}{resource, failed})
+	assert.Contains(t, exception["description"].(map[string]interface{})["title"], failed["name"])
+}
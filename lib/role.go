@@ -19,6 +19,8 @@ package chef_load
 
 import (
 	"encoding/json"
+	"sync"
+	"time"
 
 	"github.com/go-chef/chef"
 )
@@ -34,9 +36,15 @@ type role struct {
 	RunList            []string                   `json:"run_list"`
 }
 
-func roleRunListFor(nodeClient *chef.Client, nodeName, chefVersion, roleName, chefEnvironment string, requests chan *request) runList {
-	var r role
-	apiRequest(*nodeClient, nodeName, chefVersion, "GET", "roles/"+roleName, nil, &r, nil, requests)
+// roleRunListFor returns the run list of the role for the environment
+func roleRunListFor(nodeClient *chef.Client, nodeName, chefVersion, roleName, chefEnvironment string, roleCache *nodeRoleCache, requests chan *request) (runList, error) {
+	r, cached := roleCache.get(roleName)
+	if !cached {
+		if _, err := apiRequest(*nodeClient, nodeName, chefVersion, "GET", "roles/"+roleName, nil, &r, nil, requests); err != nil {
+			return nil, err
+		}
+		roleCache.put(roleName, r)
+	}
 
 	var roleRunList runList
 	envRunList, envRunListExists := r.EnvRunLists[chefEnvironment]
@@ -45,5 +53,65 @@ func roleRunListFor(nodeClient *chef.Client, nodeName, chefVersion, roleName, ch
 	} else {
 		roleRunList = parseRunList(r.RunList)
 	}
-	return roleRunList
+	return roleRunList, nil
+}
+
+type cachedRole struct {
+	role      role
+	fetchedAt time.Time
+}
+
+// nodeRoleCache holds the roles that a node fetched for ttl, a nil cache holds nothing
+type nodeRoleCache struct {
+	ttl   time.Duration
+	roles map[string]cachedRole
+}
+
+func (c *nodeRoleCache) get(roleName string) (role, bool) {
+	if c == nil {
+		return role{}, false
+	}
+	cached, ok := c.roles[roleName]
+	if !ok || time.Since(cached.fetchedAt) > c.ttl {
+		return role{}, false
+	}
+	return cached.role, true
+}
+
+func (c *nodeRoleCache) put(roleName string, r role) {
+	if c == nil {
+		return
+	}
+	c.roles[roleName] = cachedRole{role: r, fetchedAt: time.Now()}
+}
+
+// roleCaches holds the role cache of every node
+type roleCaches struct {
+	mu    sync.Mutex
+	nodes map[string]*nodeRoleCache
+}
+
+var nodeRoleCaches = &roleCaches{nodes: map[string]*nodeRoleCache{}}
+
+// node returns the role cache of the node, nil when role_cache_ttl is 0. The runs
+// of a node never overlap so the cache itself isn't locked
+func (c *roleCaches) node(nodeName string, ttlMinutes int) *nodeRoleCache {
+	if ttlMinutes <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cache, ok := c.nodes[nodeName]
+	if !ok {
+		cache = &nodeRoleCache{ttl: time.Duration(ttlMinutes) * time.Minute, roles: map[string]cachedRole{}}
+		c.nodes[nodeName] = cache
+	}
+	return cache
+}
+
+// remove forgets the role cache of a node that was replaced
+func (c *roleCaches) remove(nodeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.nodes, nodeName)
 }
@@ -18,9 +18,12 @@
 package chef_load
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/go-chef/chef"
+	log "github.com/sirupsen/logrus"
 )
 
 type runListItem struct {
@@ -57,14 +60,34 @@ func (rl runList) toStringSlice() []string {
 	return stringSlice
 }
 
-func (rl runList) expand(nodeClient *chef.Client, nodeName, chefVersion, chefEnvironment string, requests chan *request) []string {
+// recipes returns the recipes of the run list without expanding its roles
+func (rl runList) recipes() []string {
 	recipes := []string{}
-	appliedRoles := map[string]bool{}
-	expandRunList(nodeClient, nodeName, chefVersion, rl, &recipes, &appliedRoles, chefEnvironment, requests)
+	for _, rli := range rl {
+		if rli.itemType != "recipe" {
+			continue
+		}
+		if rli.version == "" {
+			recipes = append(recipes, rli.name)
+		} else {
+			recipes = append(recipes, rli.name+"@"+rli.version)
+		}
+	}
 	return recipes
 }
 
-func expandRunList(nodeClient *chef.Client, nodeName, chefVersion string, rl runList, recipesPtr *[]string, appliedRolesPtr *map[string]bool, chefEnvironment string, requests chan *request) {
+// expand returns the recipes of the run list with its roles expanded, it fails
+// when a role can't be fetched like chef-client does
+func (rl runList) expand(nodeClient *chef.Client, nodeName, chefVersion, chefEnvironment string, roleCache *nodeRoleCache, requests chan *request) ([]string, error) {
+	recipes := []string{}
+	appliedRoles := map[string]bool{}
+	err := expandRunList(nodeClient, nodeName, chefVersion, rl, &recipes, &appliedRoles, nil, chefEnvironment, roleCache, requests)
+	return recipes, err
+}
+
+// expandRunList expands the roles depth first, roleStack holds the roles that
+// are being expanded to detect the roles that include themselves
+func expandRunList(nodeClient *chef.Client, nodeName, chefVersion string, rl runList, recipesPtr *[]string, appliedRolesPtr *map[string]bool, roleStack []string, chefEnvironment string, roleCache *nodeRoleCache, requests chan *request) error {
 	var entry runListItem
 	if rl.length() > 0 {
 		entry, rl = rl.shift()
@@ -78,14 +101,36 @@ func expandRunList(nodeClient *chef.Client, nodeName, chefVersion string, rl run
 			}
 			*recipesPtr = append(*recipesPtr, recipe)
 		case "role":
-			if !(*appliedRolesPtr)[entry.name] {
+			if inRoleStack(roleStack, entry.name) {
+				// chef-client skips the roles that were already applied, so a cycle doesn't fail the run
+				log.WithFields(log.Fields{
+					"node_name": nodeName,
+					"cycle":     strings.Join(append(roleStack, entry.name), " -> "),
+				}).Warn("Role cycle detected")
+			} else if !(*appliedRolesPtr)[entry.name] {
 				(*appliedRolesPtr)[entry.name] = true
-				roleRunList := roleRunListFor(nodeClient, nodeName, chefVersion, entry.name, chefEnvironment, requests)
-				expandRunList(nodeClient, nodeName, chefVersion, roleRunList, recipesPtr, appliedRolesPtr, chefEnvironment, requests)
+				roleRunList, err := roleRunListFor(nodeClient, nodeName, chefVersion, entry.name, chefEnvironment, roleCache, requests)
+				if err != nil {
+					return fmt.Errorf("role[%s]: %s", entry.name, err)
+				}
+				err = expandRunList(nodeClient, nodeName, chefVersion, roleRunList, recipesPtr, appliedRolesPtr, append(roleStack, entry.name), chefEnvironment, roleCache, requests)
+				if err != nil {
+					return err
+				}
 			}
 		}
-		expandRunList(nodeClient, nodeName, chefVersion, rl, recipesPtr, appliedRolesPtr, chefEnvironment, requests)
+		return expandRunList(nodeClient, nodeName, chefVersion, rl, recipesPtr, appliedRolesPtr, roleStack, chefEnvironment, roleCache, requests)
+	}
+	return nil
+}
+
+func inRoleStack(roleStack []string, roleName string) bool {
+	for _, name := range roleStack {
+		if name == roleName {
+			return true
+		}
 	}
+	return false
 }
 
 func solveRunListDependencies(nodeClient *chef.Client, nodeName, chefVersion, chefEnvironment string, expandedRunList []string, requests chan *request) cookbooks {
@@ -93,6 +138,7 @@ func solveRunListDependencies(nodeClient *chef.Client, nodeName, chefVersion, ch
 
 	var ckbks cookbooks
 	apiRequest(*nodeClient, nodeName, chefVersion, "POST", "environments/"+chefEnvironment+"/cookbook_versions", body, &ckbks, nil, requests)
+	releasedCookbooks.apply(ckbks)
 	return ckbks
 }
 
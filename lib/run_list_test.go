@@ -0,0 +1,41 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunListRecipes(t *testing.T) {
+	rl := parseRunList([]string{"recipe[base]", "role[web]", "nginx@1.2.0"})
+	assert.Equal(t, []string{"base", "nginx@1.2.0"}, rl.recipes())
+}
+
+func TestRunListExpandCachedRoles(t *testing.T) {
+	cache := nodeRoleCaches.node("expand-test-node", 30)
+	defer nodeRoleCaches.remove("expand-test-node")
+	cache.put("web", role{
+		RunList:     []string{"recipe[nginx]", "role[base]"},
+		EnvRunLists: map[string][]string{"production": {"recipe[nginx@2.0.0]", "role[base]"}},
+	})
+	// base includes web again, the cycle is skipped instead of expanded forever
+	cache.put("base", role{RunList: []string{"recipe[ntp]", "role[web]"}})
+
+	rl := parseRunList([]string{"role[web]", "recipe[app]", "role[base]"})
+
+	recipes, err := rl.expand(nil, "expand-test-node", "13.2.20", "_default", cache, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx", "ntp", "app"}, recipes)
+
+	recipes, err = rl.expand(nil, "expand-test-node", "13.2.20", "production", cache, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx@2.0.0", "ntp", "app"}, recipes)
+}
+
+func TestRoleCachesDisabled(t *testing.T) {
+	cache := nodeRoleCaches.node("disabled-test-node", 0)
+	assert.Nil(t, cache)
+	cache.put("web", role{})
+	_, cached := cache.get("web")
+	assert.False(t, cached)
+}
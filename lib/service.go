@@ -50,6 +50,7 @@ type request struct {
 	Method     string `json:"method"`
 	Url        string `json:"url"`
 	StatusCode int    `json:"status_code"`
+	Bytes      int64  `json:"bytes"`
 }
 
 var logger = log.New()
@@ -59,7 +60,9 @@ const DateTimeFormat = "2006-01-02T15:04:05Z"
 func Start(config *Config) {
 	var (
 		requestAggregator = make(amountOfRequests)
+		bytesAggregator   = make(bytesOfRequests)
 		requests          = make(chan *request)
+		phases            = make(chan *requestPhase)
 	)
 
 	logger.Formatter = UTCFormatter{&log.JSONFormatter{}}
@@ -81,13 +84,15 @@ func Start(config *Config) {
 		"interval":            config.Interval,
 		"prefix":              config.NodeNamePrefix,
 		"skip-create-clients": config.SkipClientCreation,
+		"liveness-only":       config.LivenessOnlyNodes,
 	}).Info("Starting chef-load")
 
 	var (
+		livenessNodes         = livenessNodeNames(config)
 		delayBetweenConverges = time.Duration(math.Ceil(float64(time.Duration(config.Interval)*(time.Minute/time.Nanosecond))/float64(config.NumNodes))) * time.Nanosecond
 
-		// hardcode each node's liveness ping interval to 30 minutes
-		delayBetweenLivenessAgentPing = time.Duration(math.Ceil(float64(time.Duration(30)*(time.Minute/time.Nanosecond))/float64(config.NumNodes))) * time.Nanosecond
+		// spread each node's liveness ping evenly across the liveness interval
+		delayBetweenLivenessAgentPing = time.Duration(math.Ceil(float64(time.Duration(config.LivenessInterval)*(time.Minute/time.Nanosecond))/float64(len(livenessNodes)))) * time.Nanosecond
 	)
 
 	log.Printf("Delay between converges = %s\n", delayBetweenConverges)
@@ -103,13 +108,23 @@ func Start(config *Config) {
 		sigs := make(chan os.Signal, 1)
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
 
+		var phase *requestPhase
 		for {
 			select {
 			case req := <-requests:
 				requestAggregator.addRequest(request{Method: req.Method, Url: req.Url, StatusCode: req.StatusCode})
+				bytesAggregator.addBytes(*req)
+				if phase != nil {
+					phase.addRequest(*req)
+				}
+			case p := <-phases:
+				if p == nil && phase != nil {
+					phase.print()
+				}
+				phase = p
 			case sig := <-sigs:
 				log.WithFields(log.Fields{"syscall": sig}).Info("Signal received")
-				printAPIRequestProfile(startTime, requestAggregator)
+				printAPIRequestProfile(startTime, requestAggregator, bytesAggregator)
 				log.Info("Stopping chef-load")
 				os.Exit(0)
 			}
@@ -132,10 +147,9 @@ func Start(config *Config) {
 
 			// Never stop sending liveness ping
 			for {
-				for i := 1; i <= config.NumNodes; i++ {
-					nodeName := config.NodeNamePrefix + "-" + strconv.Itoa(i)
-					go livenessPing(nodeName, chefServerURL, dataCollectorClient)
-					time.Sleep(delayBetweenLivenessAgentPing)
+				for _, nodeName := range livenessNodes {
+					go livenessPing(nodeName, chefServerURL, dataCollectorClient, time.Now())
+					time.Sleep(jitter(delayBetweenLivenessAgentPing, config.LivenessJitter))
 				}
 			}
 		}()
@@ -150,15 +164,30 @@ func Start(config *Config) {
 				SkipSSL: true,
 			}, requests)
 
-			// Never stop sending actions
+			// Never stop sending actions, recorded within the last week of the
+			// current time
+			generator := newActionGenerator(config, 0, time.Time{})
 			for {
 				for i := 1; i <= config.NumActions; i++ {
-					go chefAction(config, randomActionType(), dataCollectorClient)
+					go chefAction(generator, generator.randomActionType(), dataCollectorClient)
 					time.Sleep(delayBetweenActions)
 				}
 			}
 		}()
 	}
+	// The cookbook release storm goroutine
+	if config.ReleaseStorm != nil && config.ReleaseStorm.Enabled {
+		var dataCollectorClient *DataCollectorClient
+		if config.DataCollectorURL != "" {
+			dataCollectorClient, _ = NewDataCollectorClient(&DataCollectorConfig{
+				Token:   config.DataCollectorToken,
+				URL:     config.DataCollectorURL,
+				SkipSSL: true,
+			}, requests)
+		}
+		go startReleaseStorm(config, dataCollectorClient, phases, requests)
+	}
+
 	// Cleanup: split these sections into their own functions
 
 	// The Nodes (CCRs) goroutine
@@ -175,6 +204,18 @@ func Start(config *Config) {
 		ccrCompletion <- i // trigger the first run for node 'i'
 	}
 
+	// Send the node and client create/delete actions of replaced nodes
+	var replacementActions *actionSimulation
+	var replacementActionsClient *DataCollectorClient
+	if config.SimulatedActions && config.DataCollectorURL != "" {
+		replacementActions = newActionSimulation(config)
+		replacementActionsClient, _ = NewDataCollectorClient(&DataCollectorConfig{
+			Token:   config.DataCollectorToken,
+			URL:     config.DataCollectorURL,
+			SkipSSL: true,
+		}, requests)
+	}
+
 	var timeout = false
 	//var lastRunStart = time.Now()
 	for {
@@ -185,8 +226,18 @@ func Start(config *Config) {
 		case n := <-ccrCompletion:
 			timeout = false
 			if rand.Float64() < config.NodeReplacementRate {
+				replacedNodeName := nodes[n].NodeName
+				cookbookFileCaches.remove(replacedNodeName)
+				nodeRoleCaches.remove(replacedNodeName)
 				nodes[n] = runner{NodeName: config.NodeNamePrefix + "-" + strconv.Itoa(nodeNameIdx), FirstRun: true}
 				nodeNameIdx++
+				if replacementActions != nil {
+					go func(actions []*actionRequest) {
+						for _, action := range actions {
+							sendChefAction(action, replacementActionsClient)
+						}
+					}(replacementActions.nodeReplacementActions(replacedNodeName, nodes[n].NodeName))
+				}
 			}
 			// confirming that throttle effect ensures we have a maximum of NumNodes concurrent CCRs happening
 			// log.Printf("[node %s] starting CCR. Elapsed since most recent CCR on any node: %s", nodes[n].NodeName, time.Since(lastRunStart))
@@ -0,0 +1,124 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+// When unified_nodes is enabled the converge nodes also report compliance,
+// this file derives the details that both kinds of messages share from the
+// node name so that Automate joins them like it does with real nodes
+
+import (
+	"encoding/binary"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// unifiedNode is a converge node together with the run list it converges with
+type unifiedNode struct {
+	NodeDetails
+	runList runList
+}
+
+// newUnifiedNode returns the details of the converge node, every detail is
+// derived from the node name so that they are the same on every run
+func newUnifiedNode(config *Config, nodeName string) unifiedNode {
+	var (
+		nodeUUID = uuid.NewMD5(uuid.NameSpaceDNS, []byte(nodeName))
+		hash     = binary.BigEndian.Uint32(nodeUUID[:4])
+		runLists = parseRunLists(config.RunLists)
+		rl       = parseRunList(config.RunList)
+		platform = "rhel"
+		orgName  string
+		fqdn     string
+	)
+
+	if len(runLists) > 0 {
+		rl = runLists[hash%uint32(len(runLists))]
+	}
+	if config.Matrix != nil && len(config.Matrix.Samples.Platforms) > 0 {
+		platforms := config.Matrix.Samples.Platforms
+		platform = platforms[hash%uint32(len(platforms))].Name
+	}
+	if chefServerURL, err := url.Parse(config.ChefServerURL); err == nil {
+		fqdn = chefServerURL.Host
+		if path := strings.Split(chefServerURL.Path, "/"); len(path) > 2 {
+			orgName = path[2]
+		}
+	}
+
+	roles := []string{}
+	recipes := []string{}
+	for _, rli := range rl {
+		switch rli.itemType {
+		case "role":
+			roles = append(roles, rli.name)
+		case "recipe":
+			recipes = append(recipes, rli.name)
+		}
+	}
+
+	return unifiedNode{
+		NodeDetails: NodeDetails{
+			name: nodeName,
+			// A private 10.0.0.0/8 address
+			ipAddr:      int2ip(10<<24 | hash&0xffffff).String(),
+			environment: config.ChefEnvironment,
+			roles:       roles,
+			recipes:     recipes,
+			nodeUUID:    nodeUUID,
+			platform:    platform,
+			sourceFqdn:  fqdn,
+			fqdn:        nodeName,
+			orgName:     orgName,
+			policyGroup: "hello_policy_group",
+			policyName:  "hello_policy_name",
+			chefTags:    []string{"tag1", "tag2", "tag3"},
+		},
+		runList: rl,
+	}
+}
+
+// setAutomaticAttributes makes the ohai data of the node match its details
+func (n unifiedNode) setAutomaticAttributes(attributes map[string]interface{}) {
+	attributes["fqdn"] = n.fqdn
+	attributes["platform"] = n.platform
+	attributes["ipaddress"] = n.ipAddr
+	attributes["roles"] = n.roles
+	attributes["recipes"] = n.recipes
+}
+
+// hasComplianceSamples returns whether the compliance reports of the matrix
+// platforms can be generated
+func hasComplianceSamples(config *Config) bool {
+	if config.Matrix == nil || len(config.Matrix.Samples.Platforms) == 0 {
+		return false
+	}
+	return config.Matrix.Synthetic.Enabled || config.ComplianceSampleReportsDir != ""
+}
+
+// unifiedComplianceNodes returns the converge nodes of the generate command,
+// scanned at the frequencies of matrix.simulation
+func unifiedComplianceNodes(config *Config) []complianceNode {
+	nodes := make([]NodeDetails, config.NumNodes)
+	for i := range nodes {
+		nodes[i] = newUnifiedNode(config, config.NodeNamePrefix+"-"+strconv.Itoa(i+1)).NodeDetails
+	}
+	return simulationNodes(config, nodes)
+}
@@ -0,0 +1,34 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUnifiedNode(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/organizations/demo/"
+	config.ChefEnvironment = "production"
+	config.RunLists = [][]string{{"role[web]", "recipe[nginx]"}, {"role[db]", "recipe[mysql::server]"}}
+
+	node := newUnifiedNode(&config, "chef-load-1")
+	assert.Equal(t, node, newUnifiedNode(&config, "chef-load-1"))
+	assert.Equal(t, "chef-load-1", node.name)
+	assert.Equal(t, "production", node.environment)
+	assert.Equal(t, "demo", node.orgName)
+	assert.Equal(t, "chef.example.com", node.sourceFqdn)
+	assert.Len(t, node.roles, 1)
+	assert.Len(t, node.recipes, 1)
+	assert.Equal(t, node.runList.toStringSlice()[0], "role["+node.roles[0]+"]")
+	assert.Contains(t, node.ipAddr, "10.")
+
+	attributes := map[string]interface{}{}
+	node.setAutomaticAttributes(attributes)
+	assert.Equal(t, node.platform, attributes["platform"])
+	assert.Equal(t, node.ipAddr, attributes["ipaddress"])
+
+	nodes := unifiedComplianceNodes(&config)
+	assert.Len(t, nodes, config.NumNodes)
+	assert.Equal(t, node.NodeDetails, nodes[0].NodeDetails)
+}
@@ -46,7 +46,7 @@ func apiRequest(nodeClient chef.Client, nodeName, chefVersion, method, url strin
 	}
 
 	req, _ := nodeClient.NewRequest(method, url, bodyJSON)
-	req.Header.Set("X-Ops-Server-Api-Version", "1")
+	req.Header.Set("X-Ops-Server-Api-Version", chefClientProfileFor(chefVersion).apiVersion)
 	req.Header.Set("X-Chef-Version", chefVersion)
 	for name, value := range headers {
 		req.Header.Set(name, value)
@@ -60,10 +60,17 @@ func apiRequest(nodeClient chef.Client, nodeName, chefVersion, method, url strin
 		statusCode = res.StatusCode
 	}
 
+	// The body was already read into memory by the client
+	var responseBody []byte
+	if err == nil && res != nil {
+		responseBody, _ = ioutil.ReadAll(res.Body)
+	}
+
 	requests <- &request{
 		Method:     req.Method,
 		Url:        req.URL.String(),
 		StatusCode: statusCode,
+		Bytes:      int64(len(responseBody)),
 	}
 
 	logger.WithFields(log.Fields{
@@ -74,12 +81,47 @@ func apiRequest(nodeClient chef.Client, nodeName, chefVersion, method, url strin
 		"request_time_seconds": float64(request_time.Nanoseconds()/1e6) / 1000,
 	}).Info("API Request")
 
+	return res, err
+}
+
+// streamRequest makes a GET request and discards the body as it arrives
+// instead of reading it into memory like apiRequest does
+func streamRequest(nodeClient chef.Client, nodeName, chefVersion, url string, requests chan *request) (*http.Response, int64, error) {
+	req, err := nodeClient.NewRequest("GET", url, nil)
 	if err != nil {
-		return res, err
+		return nil, 0, err
 	}
+	req.Header.Set("X-Ops-Server-Api-Version", chefClientProfileFor(chefVersion).apiVersion)
+	req.Header.Set("X-Chef-Version", chefVersion)
 
-	ioutil.ReadAll(res.Body)
-	return res, err
+	t0 := time.Now()
+	res, err := nodeClient.Client.Do(req)
+	var bytes int64
+	statusCode := 999
+	if res != nil {
+		defer res.Body.Close()
+		statusCode = res.StatusCode
+		bytes, _ = io.Copy(ioutil.Discard, res.Body)
+	}
+	request_time := time.Now().Sub(t0)
+
+	requests <- &request{
+		Method:     req.Method,
+		Url:        req.URL.String(),
+		StatusCode: statusCode,
+		Bytes:      bytes,
+	}
+
+	logger.WithFields(log.Fields{
+		"node_name":            nodeName,
+		"method":               req.Method,
+		"url":                  req.URL.String(),
+		"status_code":          statusCode,
+		"bytes":                bytes,
+		"request_time_seconds": float64(request_time.Nanoseconds()/1e6) / 1000,
+	}).Info("API Request")
+
+	return res, bytes, err
 }
 
 func getAPIClient(clientName, privateKeyPath, chefServerURL string) chef.Client {
@@ -107,21 +149,26 @@ func getPrivateKey(privateKeyPath string) string {
 }
 
 func parseJSONFile(jsonFile string) map[string]interface{} {
+	jsonContent, err := readJSONFile(jsonFile)
+	if err != nil {
+		log.WithField("error", err).Errorf("Could not load JSON file %s", jsonFile)
+		return map[string]interface{}{}
+	}
+	return jsonContent
+}
+
+// readJSONFile decodes the JSON object of the provided file
+func readJSONFile(jsonFile string) (map[string]interface{}, error) {
 	jsonContent := map[string]interface{}{}
 
 	file, err := os.Open(jsonFile)
 	if err != nil {
-		log.WithField("error", err).Errorf("Could not open JSON file %s", jsonFile)
-		return jsonContent
+		return jsonContent, err
 	}
 	defer file.Close()
 
 	err = json.NewDecoder(file).Decode(&jsonContent)
-	if err != nil {
-		log.WithField("error", err).Errorf("Could not decode JSON file %s", jsonFile)
-		return jsonContent
-	}
-	return jsonContent
+	return jsonContent, err
 }
 
 type amountOfRequests map[request]uint64
@@ -129,19 +176,42 @@ type amountOfRequests map[request]uint64
 var bookshelfRE = regexp.MustCompile("/bookshelf/.*")
 var nodeRE = regexp.MustCompile("(/nodes/.*-)\\d+(/.*)?")
 var rolesRE = regexp.MustCompile("/roles/.*")
+var searchRE = regexp.MustCompile(`(/search/[^/?]+)\?.*`)
+var dataBagItemRE = regexp.MustCompile("(/data/[^/]+)/.*")
 
-func (a amountOfRequests) addRequest(req request) {
+// aggregateURL replaces the parts of the URL that change between requests
+// to the same endpoint
+func aggregateURL(url string) string {
 	// bookshelf/anything -> bookshelf/<...>
-	req.Url = bookshelfRE.ReplaceAllString(req.Url, "/bookshelf/<...>")
+	url = bookshelfRE.ReplaceAllString(url, "/bookshelf/<...>")
 	// nodes/prefix-number[/object] -> nodes/prefix<N>[/object]
-	req.Url = nodeRE.ReplaceAllString(req.Url, "$1<N>$2")
+	url = nodeRE.ReplaceAllString(url, "$1<N>$2")
 	// We may want to further aggregate based on object type
 	// roles/anything -> roles/<ROLENAME>
-	req.Url = rolesRE.ReplaceAllString(req.Url, "/roles/<ROLENAME>")
+	url = rolesRE.ReplaceAllString(url, "/roles/<ROLENAME>")
+	// search/index?query -> search/index?<QUERY>
+	url = searchRE.ReplaceAllString(url, "$1?<QUERY>")
+	// data/bag/item -> data/bag/<ITEM>
+	url = dataBagItemRE.ReplaceAllString(url, "$1/<ITEM>")
+	return url
+}
+
+func (a amountOfRequests) addRequest(req request) {
+	req.Url = aggregateURL(req.Url)
 	a[req]++
 }
 
-func printAPIRequestProfile(startTime time.Time, numRequests map[request]uint64) {
+// bytesOfRequests holds the bytes of the response bodies of every endpoint
+type bytesOfRequests map[request]uint64
+
+func (b bytesOfRequests) addBytes(req request) {
+	if req.Bytes <= 0 {
+		return
+	}
+	b[request{Method: req.Method, Url: aggregateURL(req.Url)}] += uint64(req.Bytes)
+}
+
+func printAPIRequestProfile(startTime time.Time, numRequests map[request]uint64, numBytes bytesOfRequests) {
 	log.Info("Printing profile of API requests")
 
 	var (
@@ -188,4 +258,33 @@ func printAPIRequestProfile(startTime time.Time, numRequests map[request]uint64)
 		log.Info(fmt.Sprintf("%-10.2f   %-*d   %-6d   %-6s   %s",
 			percentOfTotal, amountFieldWidth, count, request.StatusCode, request.Method, request.Url))
 	}
+
+	printBytesProfile(elapsed, numBytes)
+}
+
+// printBytesProfile prints the bytes downloaded from every endpoint
+func printBytesProfile(elapsed time.Duration, numBytes bytesOfRequests) {
+	if len(numBytes) == 0 {
+		return
+	}
+
+	var (
+		requests   []request
+		totalBytes uint64
+	)
+	for request, bytes := range numBytes {
+		requests = append(requests, request)
+		totalBytes += bytes
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return numBytes[requests[i]] > numBytes[requests[j]]
+	})
+
+	log.Info(fmt.Sprintf("Total bytes downloaded: %d over %s. Bytes per second: %d", totalBytes, elapsed, int64(float64(totalBytes)/elapsed.Seconds())))
+	log.Info(fmt.Sprintf("%% of Total | %-14s | Method | URL", "Bytes"))
+	for _, request := range requests {
+		bytes := numBytes[request]
+		log.Info(fmt.Sprintf("%-10.2f   %-14d   %-6s   %s",
+			float64(bytes)/float64(totalBytes)*100.0, bytes, request.Method, request.Url))
+	}
 }
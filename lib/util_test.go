@@ -0,0 +1,27 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesOfRequests(t *testing.T) {
+	numBytes := make(bytesOfRequests)
+	numBytes.addBytes(request{Method: "GET", Url: "https://chef.example.com/bookshelf/organization-1/checksum-a", StatusCode: 200, Bytes: 100})
+	numBytes.addBytes(request{Method: "GET", Url: "https://chef.example.com/bookshelf/organization-1/checksum-b", StatusCode: 200, Bytes: 50})
+	numBytes.addBytes(request{Method: "GET", Url: "https://chef.example.com/organizations/demo/nodes/chef-load-1", StatusCode: 200})
+
+	assert.Equal(t, bytesOfRequests{
+		request{Method: "GET", Url: "https://chef.example.com/bookshelf/<...>"}: 150,
+	}, numBytes)
+}
+
+func TestAggregateURL(t *testing.T) {
+	assert.Equal(t, "https://chef.example.com/organizations/demo/search/node?<QUERY>",
+		aggregateURL("https://chef.example.com/organizations/demo/search/node?q=role%3Aweb&start=0&rows=1000"))
+	assert.Equal(t, "https://chef.example.com/organizations/demo/data/users/<ITEM>",
+		aggregateURL("https://chef.example.com/organizations/demo/data/users/user-42"))
+	assert.Equal(t, "https://chef.example.com/organizations/demo/roles/<ROLENAME>",
+		aggregateURL("https://chef.example.com/organizations/demo/roles/web"))
+}
@@ -0,0 +1,483 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var orgPathRE = regexp.MustCompile("^/organizations/[^/]+/")
+
+// ValidateConfig checks the config and every file that it references before
+// a run starts and returns all the problems that it finds. The sample reports
+// of the compliance matrix are only checked when checkComplianceSamples is true.
+func ValidateConfig(config *Config, checkComplianceSamples bool) []error {
+	var problems []error
+
+	if config.Interval <= 0 {
+		problems = append(problems, errors.New("interval must be greater than zero"))
+	}
+	if config.Threads <= 0 {
+		problems = append(problems, errors.New("threads must be greater than zero"))
+	}
+
+	switch config.ActionTimeDistribution {
+	case "uniform", "business_hours", "bursty":
+	default:
+		problems = append(problems, errors.New("action_time_distribution must be one of uniform, business_hours or bursty"))
+	}
+	if config.Actions != nil {
+		if err := config.Actions.Validate(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if config.LivenessAgent {
+		problems = append(problems, validateLiveness(config)...)
+	}
+
+	if config.ReportingTotalResources < 0 || config.ReportingUpdatedResources < 0 {
+		problems = append(problems, errors.New("reporting_total_resources and reporting_updated_resources must not be negative"))
+	}
+
+	for _, chefVersion := range config.ChefVersions {
+		if chefVersion.Version == "" {
+			problems = append(problems, errors.New("every chef_versions entry must set a version"))
+		}
+		if chefVersion.Weight < 0 {
+			problems = append(problems, fmt.Errorf("chef_versions weight of %q must not be negative", chefVersion.Version))
+		}
+	}
+	if (config.PolicyName == "") != (config.PolicyGroup == "") {
+		problems = append(problems, errors.New("policy_name and policy_group must be set together"))
+	}
+
+	if workload := config.Workload; workload != nil && workload.CallsPerRun > 0 {
+		if len(workload.Searches) == 0 && len(workload.DataBagItems) == 0 {
+			problems = append(problems, errors.New("workload.calls_per_run requires workload.searches or workload.data_bag_items"))
+		}
+		for _, search := range workload.Searches {
+			if search.Index == "" || search.Query == "" {
+				problems = append(problems, errors.New("every workload search must set an index and a query"))
+			}
+			if search.Weight <= 0 {
+				problems = append(problems, fmt.Errorf("workload search %q must have a weight greater than zero", search.Query))
+			}
+		}
+		for _, item := range workload.DataBagItems {
+			if item.Bag == "" || item.Item == "" {
+				problems = append(problems, errors.New("every workload data bag item must set a bag and an item"))
+			}
+			if item.Weight <= 0 {
+				problems = append(problems, fmt.Errorf("workload data bag item %s/%s must have a weight greater than zero", item.Bag, item.Item))
+			}
+		}
+	}
+
+	for _, cookbookRequest := range config.APIRequests {
+		switch strings.ToUpper(cookbookRequest.Method) {
+		case "GET", "POST", "PUT", "DELETE", "HEAD", "PATCH":
+		default:
+			problems = append(problems, fmt.Errorf("api_requests method %q of %q must be one of GET, POST, PUT, DELETE, HEAD or PATCH", cookbookRequest.Method, cookbookRequest.Path))
+		}
+		if cookbookRequest.Path == "" {
+			problems = append(problems, errors.New("every api_requests entry must set a path"))
+		}
+		if cookbookRequest.Probability <= 0 || cookbookRequest.Probability > 1 {
+			problems = append(problems, fmt.Errorf("api_requests probability of %q must be greater than 0.0 and at most 1.0", cookbookRequest.Path))
+		}
+		if cookbookRequest.Body != "" {
+			var body interface{}
+			sampleVars := templateVars{nodeName: "node", environment: "_default", roles: []string{"role"}}
+			if err := json.Unmarshal([]byte(sampleVars.expand(cookbookRequest.Body)), &body); err != nil {
+				problems = append(problems, fmt.Errorf("api_requests body of %q is not JSON: %s", cookbookRequest.Path, err))
+			}
+		}
+	}
+
+	if cd := config.ConvergeDuration; cd != nil {
+		if len(cd.Groups) > 0 {
+			for i, group := range cd.Groups {
+				name := fmt.Sprintf("converge_duration.groups[%d]", i)
+				if group.Weight <= 0 {
+					problems = append(problems, fmt.Errorf("%s.weight must be greater than zero", name))
+				}
+				problems = append(problems, group.validate(name)...)
+			}
+		} else if cd.Distribution != "" {
+			problems = append(problems, cd.validate("converge_duration")...)
+		}
+	}
+
+	seenRunStartRequests := map[string]bool{}
+	for _, runStartRequest := range config.RunStartRequests {
+		switch runStartRequest {
+		case nodeRunStartRequest, rolesRunStartRequest, environmentRunStartRequest:
+		default:
+			problems = append(problems, fmt.Errorf("run_start_requests value %q must be one of node, roles or environment", runStartRequest))
+		}
+		if seenRunStartRequests[runStartRequest] {
+			problems = append(problems, fmt.Errorf("run_start_requests value %q is duplicated", runStartRequest))
+		}
+		seenRunStartRequests[runStartRequest] = true
+	}
+	// Without the roles request the roles aren't expanded and the policy isn't loaded
+	if !seenRunStartRequests[rolesRunStartRequest] {
+		hasRoles := len(parseRunList(config.RunList).roleNames()) > 0
+		for _, rl := range parseRunLists(config.RunLists) {
+			hasRoles = hasRoles || len(rl.roleNames()) > 0
+		}
+		if hasRoles {
+			problems = append(problems, errors.New(`run_start_requests must include "roles" when the run lists have roles`))
+		}
+		if config.PolicyName != "" {
+			problems = append(problems, errors.New(`run_start_requests must include "roles" when policy_name is set`))
+		}
+	}
+	if config.RoleCacheTTL < 0 {
+		problems = append(problems, errors.New("role_cache_ttl must not be negative"))
+	}
+
+	switch config.DownloadCookbooks {
+	case "never", "first", "always", "cache":
+	default:
+		problems = append(problems, fmt.Errorf("download_cookbooks %q must be one of never, first, always or cache", config.DownloadCookbooks))
+	}
+	if config.DownloadCookbooksConcurrency < 1 {
+		problems = append(problems, errors.New("download_cookbooks_concurrency must be at least 1"))
+	}
+	if config.DownloadCookbooks == "cache" {
+		if config.CookbookCacheEviction != staleCacheEviction && config.CookbookCacheEviction != neverCacheEviction {
+			problems = append(problems, fmt.Errorf("cookbook_cache_eviction %q must be stale or never", config.CookbookCacheEviction))
+		}
+		if config.CookbookCacheWipeRate < 0 || config.CookbookCacheWipeRate > 1 {
+			problems = append(problems, errors.New("cookbook_cache_wipe_rate must be between 0.0 and 1.0"))
+		}
+	}
+
+	if storm := config.ReleaseStorm; storm != nil && storm.Enabled {
+		if config.DownloadCookbooks != "cache" {
+			problems = append(problems, errors.New(`release_storm requires download_cookbooks = "cache"`))
+		}
+		if storm.After < 0 {
+			problems = append(problems, errors.New("release_storm.after must not be negative"))
+		}
+		if storm.ChangedFiles < 0 || storm.ChangedFiles > 1 {
+			problems = append(problems, errors.New("release_storm.changed_files must be between 0.0 and 1.0"))
+		}
+		// The cookbooks of the roles are only known once the nodes expand them
+		if len(storm.Cookbooks) == 0 && len(runListCookbooks(config)) == 0 {
+			problems = append(problems, errors.New("release_storm.cookbooks must be set when the run lists have no recipes"))
+		}
+	}
+
+	// ChefClientRun and livenessPing get the organization from the URL path
+	if chefServerURL, err := url.ParseRequestURI(config.ChefServerURL); err != nil {
+		problems = append(problems, fmt.Errorf("chef_server_url %q is not a valid URL: %s", config.ChefServerURL, err))
+	} else if !orgPathRE.MatchString(chefServerURL.Path) {
+		problems = append(problems, fmt.Errorf("chef_server_url %q must include the organization, for example https://chef.example.com/organizations/demo/", config.ChefServerURL))
+	}
+
+	if config.RunChefClient {
+		if _, err := os.Stat(config.ClientKey); err != nil {
+			problems = append(problems, fmt.Errorf("client_key: %s", err))
+		}
+	}
+
+	if config.OhaiJSONFile != "" {
+		if _, err := readJSONFile(config.OhaiJSONFile); err != nil {
+			problems = append(problems, fmt.Errorf("ohai_json_file: %s", err))
+		}
+	}
+
+	if config.ConvergeStatusJSONFile != "" {
+		if convergeJSON, err := readJSONFile(config.ConvergeStatusJSONFile); err != nil {
+			problems = append(problems, fmt.Errorf("converge_status_json_file: %s", err))
+		} else if err := validateConvergeStatus(convergeJSON); err != nil {
+			problems = append(problems, fmt.Errorf("converge_status_json_file %s: %s", config.ConvergeStatusJSONFile, err))
+		}
+	}
+
+	if config.ComplianceStatusJSONFile != "" {
+		if complianceJSON, err := readJSONFile(config.ComplianceStatusJSONFile); err != nil {
+			problems = append(problems, fmt.Errorf("compliance_status_json_file: %s", err))
+		} else if err := validateInspecReport(complianceJSON); err != nil {
+			problems = append(problems, fmt.Errorf("compliance_status_json_file %s: %s", config.ComplianceStatusJSONFile, err))
+		}
+	}
+
+	if checkComplianceSamples && config.Matrix != nil {
+		if config.Matrix.Simulation.Concurrency < 0 {
+			problems = append(problems, errors.New("matrix.simulation.concurrency must not be negative"))
+		}
+		if config.Matrix.Simulation.ScansPerSecond < 0 {
+			problems = append(problems, errors.New("matrix.simulation.scans_per_second must not be negative"))
+		}
+		if scaling := config.Matrix.Scaling; scaling.TargetBytes < 0 || scaling.TargetControls < 0 || scaling.ResultsPerControl < 0 {
+			problems = append(problems, errors.New("the targets of matrix.scaling must not be negative"))
+		}
+		if template := config.Matrix.Simulation.NodeNameTemplate; template != "" && !strings.Contains(template, "{index}") {
+			problems = append(problems, fmt.Errorf("matrix.simulation.node_name_template %q must include {index} to keep the node names unique", template))
+		}
+		// Every mode spreads the scans of the nodes with the jitter
+		if jitter := config.Matrix.Simulation.Jitter; jitter < 0 || jitter >= 1 {
+			problems = append(problems, errors.New("matrix.simulation.jitter must be between 0.0 and 1.0 (exclusive)"))
+		}
+		switch config.Matrix.Simulation.Mode {
+		case simulationComplianceMode, "":
+			if config.Matrix.Simulation.Nodes > 0 || config.UnifiedNodes {
+				problems = append(problems, validateScanFrequencies(config.Matrix.Simulation)...)
+				problems = append(problems, validateComplianceMatrix(config)...)
+			}
+		case statisticsComplianceMode, benchmarkComplianceMode:
+			for i, set := range config.Matrix.Statistics.Sets {
+				if set.ScanPerDay <= 0 || set.ScanPerDay > 1440 {
+					problems = append(problems, fmt.Errorf("scan_per_day of matrix.statistics.sets %d must be between 1 and 1440", i+1))
+				}
+			}
+			if len(config.Matrix.Statistics.Sets) > 0 {
+				problems = append(problems, validateComplianceMatrix(config)...)
+			}
+		default:
+			problems = append(problems, fmt.Errorf("matrix.simulation.mode %q must be one of simulation, statistics or benchmark", config.Matrix.Simulation.Mode))
+		}
+	}
+
+	return problems
+}
+
+// validateScanFrequencies checks the frequency buckets of the simulation
+func validateScanFrequencies(simulation Simulation) []error {
+	var problems []error
+	if len(simulation.Frequencies) == 0 {
+		if simulation.MaxScans <= 0 || simulation.MaxScans > 1440 {
+			problems = append(problems, errors.New("matrix.simulation.max_scans must be between 1 and 1440"))
+		}
+		return problems
+	}
+
+	total := 0.0
+	for i, frequency := range simulation.Frequencies {
+		if frequency.Fraction < 0 {
+			problems = append(problems, fmt.Errorf("fraction of matrix.simulation.frequencies %d must not be negative", i+1))
+		}
+		if frequency.Interval <= 0 {
+			problems = append(problems, fmt.Errorf("interval of matrix.simulation.frequencies %d must be greater than zero", i+1))
+		}
+		total += frequency.Fraction
+	}
+	if total <= 0 {
+		problems = append(problems, errors.New("the fractions of matrix.simulation.frequencies must add up to more than zero"))
+	}
+	return problems
+}
+
+// validateConvergeStatus checks the fields that dataCollectorRunStop uses
+func validateConvergeStatus(convergeJSON map[string]interface{}) error {
+	if v, ok := convergeJSON["run_list"]; ok {
+		if _, ok := v.([]interface{}); !ok {
+			return errors.New("run_list must be an array")
+		}
+	}
+	if v, ok := convergeJSON["expanded_run_list"]; ok {
+		if _, ok := v.(map[string]interface{}); !ok {
+			return errors.New("expanded_run_list must be an object")
+		}
+	}
+	if v, ok := convergeJSON["resources"]; ok {
+		if _, ok := v.([]interface{}); !ok {
+			return errors.New("resources must be an array")
+		}
+	}
+	return nil
+}
+
+// validateInspecReport checks that the report is either an InSpec "full"
+// report with profiles or a "min" report with controls
+func validateInspecReport(report map[string]interface{}) error {
+	if v, ok := report["profiles"]; ok {
+		profiles, ok := v.([]interface{})
+		if !ok {
+			return errors.New("profiles must be an array")
+		}
+		for i, p := range profiles {
+			profile, ok := p.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("profile %d must be an object", i)
+			}
+			if stringValue(profile["name"]) == "" || stringValue(profile["version"]) == "" {
+				return fmt.Errorf("profile %d must have a name and a version", i)
+			}
+			if _, ok := profile["controls"].([]interface{}); !ok {
+				return fmt.Errorf("profile %s must have an array of controls", profile["name"])
+			}
+		}
+		return nil
+	}
+
+	if v, ok := report["controls"]; ok {
+		if _, ok := v.([]interface{}); !ok {
+			return errors.New("controls must be an array")
+		}
+		return nil
+	}
+	return errors.New("an InSpec report must have profiles or controls")
+}
+
+// reportProfiles returns the profiles of the report, with the version for
+// "full" reports and only the name for "min" reports
+func reportProfiles(report map[string]interface{}) map[string]bool {
+	profiles := map[string]bool{}
+	if v, ok := report["profiles"].([]interface{}); ok {
+		for _, p := range v {
+			profile, _ := p.(map[string]interface{})
+			profiles[stringValue(profile["name"])+"-"+stringValue(profile["version"])] = true
+		}
+		return profiles
+	}
+	if v, ok := report["controls"].([]interface{}); ok {
+		for _, c := range v {
+			control, _ := c.(map[string]interface{})
+			profiles[stringValue(control["profile_id"])] = true
+		}
+	}
+	return profiles
+}
+
+// validateComplianceMatrix checks the sample report of every platform of the
+// matrix and that it includes the platform's profiles
+func validateComplianceMatrix(config *Config) []error {
+	var problems []error
+	platforms := config.Matrix.Samples.Platforms
+
+	if len(platforms) == 0 {
+		return append(problems, errors.New("matrix.samples.platforms must have at least one platform"))
+	}
+	if config.Matrix.Synthetic.Enabled {
+		return validateSynthetic(config.Matrix.Synthetic)
+	}
+	if config.ComplianceSampleReportsDir == "" {
+		return append(problems, errors.New("compliance_sample_reports_dir must be set to generate compliance reports"))
+	}
+
+	for _, platform := range platforms {
+		sampleFile := filepath.Join(config.ComplianceSampleReportsDir, fmt.Sprintf("%s-%s.json", platform.Name, config.Matrix.Simulation.SampleFormat))
+		report, err := readJSONFile(sampleFile)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("sample report of platform %s: %s", platform.Name, err))
+			continue
+		}
+		if err := validateInspecReport(report); err != nil {
+			problems = append(problems, fmt.Errorf("sample report %s: %s", sampleFile, err))
+			continue
+		}
+
+		_, fullFormat := report["profiles"]
+		sampleProfiles := reportProfiles(report)
+		for _, profile := range platform.Profiles {
+			key := profile
+			if !fullFormat {
+				key = parseComplianceProfile(profile).name
+			}
+			if !sampleProfiles[key] {
+				problems = append(problems, fmt.Errorf("profile %s of platform %s is not in sample report %s", profile, platform.Name, sampleFile))
+			}
+		}
+	}
+	return problems
+}
+
+// validateSynthetic checks the [matrix.synthetic] spec of the generated reports
+func validateSynthetic(spec Synthetic) []error {
+	var problems []error
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"profiles", spec.Profiles},
+		{"controls_per_profile", spec.ControlsPerProfile},
+		{"results_per_control", spec.ResultsPerControl},
+		{"tags_per_control", spec.TagsPerControl},
+		{"refs_per_control", spec.RefsPerControl},
+		{"code_size", spec.CodeSize},
+	}
+	for _, count := range counts {
+		if count.value < 0 {
+			problems = append(problems, fmt.Errorf("matrix.synthetic.%s must not be negative", count.name))
+		}
+	}
+
+	rates := []struct {
+		name  string
+		value float64
+	}{
+		{"failure_rate", spec.FailureRate},
+		{"skip_rate", spec.SkipRate},
+		{"waiver_rate", spec.WaiverRate},
+		{"attestation_rate", spec.AttestationRate},
+	}
+	for _, rate := range rates {
+		if rate.value < 0 || rate.value > 1 {
+			problems = append(problems, fmt.Errorf("matrix.synthetic.%s must be between 0.0 and 1.0", rate.name))
+		}
+	}
+
+	if len(spec.ImpactWeights) > 0 {
+		severities := make([]string, 0, len(spec.ImpactWeights))
+		for severity := range spec.ImpactWeights {
+			severities = append(severities, severity)
+		}
+		sort.Strings(severities)
+
+		total := 0.0
+		for _, severity := range severities {
+			weight := spec.ImpactWeights[severity]
+			if _, ok := impactRanges[severity]; !ok {
+				problems = append(problems, fmt.Errorf("matrix.synthetic.impact_weights %q must be one of %s", severity, strings.Join(impactSeverities, ", ")))
+			} else if weight < 0 {
+				problems = append(problems, fmt.Errorf("matrix.synthetic.impact_weights %q must not be negative", severity))
+			} else {
+				total += weight
+			}
+		}
+		if total <= 0 {
+			problems = append(problems, errors.New("matrix.synthetic.impact_weights must have a known severity with a weight greater than zero"))
+		}
+	}
+	return problems
+}
+
+// validateLiveness checks the settings that space the liveness pings
+func validateLiveness(config *Config) []error {
+	var problems []error
+	if config.LivenessInterval <= 0 {
+		problems = append(problems, errors.New("liveness_interval must be greater than zero"))
+	}
+	if config.LivenessJitter < 0 || config.LivenessJitter >= 1 {
+		problems = append(problems, errors.New("liveness_jitter must be between 0.0 and 1.0 (exclusive)"))
+	}
+	return problems
+}
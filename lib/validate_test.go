@@ -0,0 +1,99 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfigReportsEveryProblem(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/"
+	config.Interval = 0
+	config.ActionTimeDistribution = "weekends"
+	config.LivenessAgent = true
+	config.LivenessInterval = 0
+	config.OhaiJSONFile = "../sample-data/missing-ohai.json"
+	config.ComplianceSampleReportsDir = "../sample-data/inspec-reports"
+	config.Matrix.Simulation.Nodes = 1
+	config.Matrix.Samples.Platforms = []Platform{
+		{Name: "c6", Profiles: []string{"ssh-baseline-2.2.0", "ssh-baseline-9.9.9"}},
+		{Name: "missing", Profiles: []string{"ssh-baseline-2.2.0"}},
+	}
+
+	problems := ValidateConfig(&config, true)
+	assert.Len(t, problems, 7)
+
+	// Only the sample reports depend on the compliance matrix
+	assert.Len(t, ValidateConfig(&config, false), 5)
+}
+
+func TestValidateConfigWithSampleData(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/organizations/demo/"
+	config.OhaiJSONFile = "../sample-data/example-ohai.json"
+	config.ConvergeStatusJSONFile = "../sample-data/example-converge-status.json"
+	config.ComplianceStatusJSONFile = "../sample-data/example-compliance-status.json"
+	config.ComplianceSampleReportsDir = "../sample-data/inspec-reports"
+	config.Matrix.Simulation.Nodes = 1
+
+	assert.Empty(t, ValidateConfig(&config, true))
+
+	config.Matrix.Simulation.SampleFormat = "min"
+	assert.Empty(t, ValidateConfig(&config, true))
+}
+
+func TestValidateSynthetic(t *testing.T) {
+	spec := Synthetic{Enabled: true, ControlsPerProfile: 10, ResultsPerControl: 2, FailureRate: 0.2}
+	assert.Empty(t, validateSynthetic(spec))
+
+	spec.ControlsPerProfile = -1
+	spec.CodeSize = -1
+	spec.SkipRate = 1.5
+	assert.Len(t, validateSynthetic(spec), 3)
+
+	// Misspelled and zero weights would always choose the first severity
+	spec = Synthetic{Enabled: true, ImpactWeights: map[string]float64{"Critical": 1, "high": 0}}
+	assert.Len(t, validateSynthetic(spec), 2)
+
+	spec.ImpactWeights = map[string]float64{"critical": 1, "low": 3}
+	assert.Empty(t, validateSynthetic(spec))
+}
+
+func TestValidateJitterInEveryMode(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/organizations/demo/"
+	config.ComplianceSampleReportsDir = "../sample-data/inspec-reports"
+	config.Matrix.Simulation.Jitter = 1.5
+	for _, mode := range []string{simulationComplianceMode, statisticsComplianceMode, benchmarkComplianceMode} {
+		config.Matrix.Simulation.Mode = mode
+		assert.Len(t, ValidateConfig(&config, true), 1, mode)
+	}
+}
+
+func TestValidateReleaseStormCookbooks(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/organizations/demo/"
+	config.DownloadCookbooks = "cache"
+	config.ReleaseStorm.Enabled = true
+	config.RunList = []string{"role[web]"}
+	assert.Len(t, ValidateConfig(&config, false), 1)
+
+	config.ReleaseStorm.Cookbooks = []string{"nginx"}
+	assert.Empty(t, ValidateConfig(&config, false))
+}
+
+func TestValidateRunStartRequestsRoles(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/organizations/demo/"
+	config.RunStartRequests = []string{"node", "environment"}
+	config.RunList = []string{"recipe[base]"}
+	assert.Empty(t, ValidateConfig(&config, false))
+
+	config.RunLists = [][]string{{"recipe[base]"}, {"role[web]"}}
+	assert.Len(t, ValidateConfig(&config, false), 1)
+
+	config.PolicyName = "base"
+	config.PolicyGroup = "production"
+	assert.Len(t, ValidateConfig(&config, false), 2)
+}
@@ -0,0 +1,180 @@
+//
+// Copyright:: Copyright 2018 Chef Software, Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package chef_load
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chef/chef"
+	log "github.com/sirupsen/logrus"
+)
+
+// templateVars are the values of the variables of the API request templates
+type templateVars struct {
+	nodeName    string
+	environment string
+	roles       []string
+}
+
+var randomVarRE = regexp.MustCompile(`\{random:(\d+)\}`)
+
+// expand replaces the variables of the template:
+//
+//	{node_name}    the name of the node
+//	{environment}  the environment of the node
+//	{role}         a random role of the node's run_list, * when it has none
+//	{random:N}     a random index between 0 and N-1
+func (v templateVars) expand(template string) string {
+	role := "*"
+	if len(v.roles) > 0 {
+		role = v.roles[rand.Intn(len(v.roles))]
+	}
+	expanded := strings.NewReplacer(
+		"{node_name}", v.nodeName,
+		"{environment}", v.environment,
+		"{role}", role,
+	).Replace(template)
+
+	return randomVarRE.ReplaceAllStringFunc(expanded, func(match string) string {
+		n, _ := strconv.Atoi(randomVarRE.FindStringSubmatch(match)[1])
+		if n <= 0 {
+			return "0"
+		}
+		return strconv.Itoa(rand.Intn(n))
+	})
+}
+
+// roleNames returns the names of the roles of the run list
+func (rl runList) roleNames() []string {
+	roles := []string{}
+	for _, rli := range rl {
+		if rli.itemType == "role" {
+			roles = append(roles, rli.name)
+		}
+	}
+	return roles
+}
+
+// searchPath returns the path of a search request like the ones of chef-client,
+// which sorts by id and gets the results in pages of rows
+func (s SearchTemplate) searchPath(vars templateVars) string {
+	rows := s.Rows
+	if rows <= 0 {
+		rows = 1000
+	}
+	return "search/" + vars.expand(s.Index) +
+		"?q=" + url.QueryEscape(vars.expand(s.Query)) +
+		"&sort=X_CHEF_id_CHEF_X%20asc&start=0&rows=" + strconv.Itoa(rows)
+}
+
+// partialSearchBody returns the body of a partial search, every key is an
+// attribute path like kernel.machine
+func (s SearchTemplate) partialSearchBody() map[string][]string {
+	body := map[string][]string{}
+	for _, key := range s.PartialKeys {
+		body[key] = strings.Split(key, ".")
+	}
+	return body
+}
+
+// request makes the search, a POST when it is a partial search
+func (s SearchTemplate) request(nodeClient chef.Client, nodeName, chefVersion string, vars templateVars, requests chan *request) {
+	if len(s.PartialKeys) > 0 {
+		apiRequest(nodeClient, nodeName, chefVersion, "POST", s.searchPath(vars), s.partialSearchBody(), nil, nil, requests)
+	} else {
+		apiRequest(nodeClient, nodeName, chefVersion, "GET", s.searchPath(vars), nil, nil, nil, requests)
+	}
+}
+
+// request reads the data bag item
+func (d DataBagItemTemplate) request(nodeClient chef.Client, nodeName, chefVersion string, vars templateVars, requests chan *request) {
+	apiRequest(nodeClient, nodeName, chefVersion, "GET", "data/"+vars.expand(d.Bag)+"/"+vars.expand(d.Item), nil, nil, nil, requests)
+}
+
+// runWorkload makes the calls_per_run requests of the workload, choosing each
+// template by weight
+func runWorkload(workload *Workload, nodeClient chef.Client, nodeName, chefVersion string, vars templateVars, requests chan *request) {
+	if workload == nil || workload.CallsPerRun <= 0 {
+		return
+	}
+	numSearches := len(workload.Searches)
+	weights := make([]float64, 0, numSearches+len(workload.DataBagItems))
+	for _, search := range workload.Searches {
+		weights = append(weights, search.Weight)
+	}
+	for _, item := range workload.DataBagItems {
+		weights = append(weights, item.Weight)
+	}
+	if len(weights) == 0 {
+		return
+	}
+
+	for i := 0; i < workload.CallsPerRun; i++ {
+		if choice := weightedChoice(weights); choice < numSearches {
+			workload.Searches[choice].request(nodeClient, nodeName, chefVersion, vars, requests)
+		} else {
+			workload.DataBagItems[choice-numSearches].request(nodeClient, nodeName, chefVersion, vars, requests)
+		}
+	}
+}
+
+// request makes the API request with probability, it returns false when the
+// response doesn't have the expected status
+func (a APIRequest) request(nodeClient chef.Client, nodeName, chefVersion string, vars templateVars, requests chan *request) bool {
+	if rand.Float64() >= a.Probability {
+		return true
+	}
+
+	var body interface{}
+	if a.Body != "" {
+		if err := json.Unmarshal([]byte(vars.expand(a.Body)), &body); err != nil {
+			log.WithFields(log.Fields{"path": a.Path, "error": err}).Warn("Could not parse the body of the API request")
+			return false
+		}
+	}
+
+	statusCode := 999
+	res, _ := apiRequest(nodeClient, nodeName, chefVersion, strings.ToUpper(a.Method), vars.expand(a.Path), body, nil, nil, requests)
+	if res != nil {
+		statusCode = res.StatusCode
+	}
+	if a.expectedStatus(statusCode) {
+		return true
+	}
+	log.WithFields(log.Fields{
+		"node_name":   nodeName,
+		"method":      a.Method,
+		"path":        a.Path,
+		"status_code": statusCode,
+	}).Warn("Unexpected status of API request, the chef-client run fails")
+	return false
+}
+
+// expectedStatus returns whether the status is the expected_status, any 2xx
+// status when it isn't set
+func (a APIRequest) expectedStatus(statusCode int) bool {
+	if a.ExpectedStatus == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return statusCode == a.ExpectedStatus
+}
@@ -0,0 +1,53 @@
+package chef_load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateVarsExpand(t *testing.T) {
+	vars := templateVars{nodeName: "chef-load-1", environment: "production", roles: []string{"web"}}
+	assert.Equal(t, "role:web AND chef_environment:production AND NOT name:chef-load-1",
+		vars.expand("role:{role} AND chef_environment:{environment} AND NOT name:{node_name}"))
+	assert.Equal(t, "user-0", vars.expand("user-{random:1}"))
+	assert.Regexp(t, `^user-\d{1,2}$`, vars.expand("user-{random:100}"))
+
+	// Nodes without roles search every role
+	assert.Equal(t, "role:*", templateVars{}.expand("role:{role}"))
+}
+
+func TestSearchTemplate(t *testing.T) {
+	vars := templateVars{environment: "production", roles: []string{"web"}}
+	search := SearchTemplate{Index: "node", Query: "role:{role} AND chef_environment:{environment}"}
+	assert.Equal(t, "search/node?q=role%3Aweb+AND+chef_environment%3Aproduction&sort=X_CHEF_id_CHEF_X%20asc&start=0&rows=1000",
+		search.searchPath(vars))
+
+	search.PartialKeys = []string{"name", "kernel.machine"}
+	assert.Equal(t, map[string][]string{
+		"name":           {"name"},
+		"kernel.machine": {"kernel", "machine"},
+	}, search.partialSearchBody())
+}
+
+func TestAPIRequestExpectedStatus(t *testing.T) {
+	assert.True(t, APIRequest{}.expectedStatus(201))
+	assert.False(t, APIRequest{}.expectedStatus(404))
+	assert.True(t, APIRequest{ExpectedStatus: 404}.expectedStatus(404))
+	assert.False(t, APIRequest{ExpectedStatus: 404}.expectedStatus(200))
+}
+
+func TestValidateAPIRequests(t *testing.T) {
+	config := Default()
+	config.ChefServerURL = "https://chef.example.com/organizations/demo/"
+	config.APIRequests = []APIRequest{
+		{Method: "put", Path: "data/heartbeats/{node_name}", Body: `{"id": "{node_name}"}`, Probability: 1},
+	}
+	assert.Empty(t, ValidateConfig(&config, false))
+
+	config.APIRequests = []APIRequest{
+		{Method: "FETCH", Path: "nodes/{node_name}", Body: `{"id": {node_name}}`},
+	}
+	// The method, the probability and the body are wrong
+	assert.Len(t, ValidateConfig(&config, false), 3)
+}